@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	"net/http"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"strings"
+)
+
+const scaleWebhookPath = "/validate-example-com-v1-jsonserver-scale"
+
+// +kubebuilder:webhook:path=/validate-example-com-v1-jsonserver-scale,mutating=false,failurePolicy=fail,sideEffects=None,groups=example.com,resources=jsonservers/scale,verbs=update,versions=v1,name=vjsonserverscale.kb.io,admissionReviewVersions=v1
+
+// scaleValidator validates updates of the scale subresource (kubectl scale, HorizontalPodAutoscaler),
+// they do not go through JsonServer webhooks
+type scaleValidator struct {
+	decoder *admission.Decoder
+}
+
+func setupScaleWebhookWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(scaleWebhookPath, &webhook.Admission{Handler: &scaleValidator{decoder: admission.NewDecoder(mgr.GetScheme())}})
+}
+
+func (v *scaleValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	scale := &autoscalingv1.Scale{}
+	if err := v.decoder.Decode(req, scale); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+	jsonserverlog.Info("webhook validate scale", "name", req.Name, "replicas", scale.Spec.Replicas)
+	validationErrors := validateReplicas(scale.Spec.Replicas)
+	if len(validationErrors) == 0 && apiReader != nil {
+		jsonServer := &JsonServer{}
+		if err := apiReader.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: req.Name}, jsonServer); err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		jsonServer.Spec.Replicas = &scale.Spec.Replicas
+		if err := checkQuotas(jsonServer); err != nil {
+			validationErrors = append(validationErrors, err.Error())
+		}
+	}
+	if len(validationErrors) > 0 {
+		jsonserverlog.Info("scale validation issues", "name", req.Name, "issues", strings.Join(validationErrors, ";"))
+		return admission.Denied(fmt.Sprintf("validation issues: %s", strings.Join(validationErrors, "; ")))
+	}
+	return admission.Allowed("")
+}
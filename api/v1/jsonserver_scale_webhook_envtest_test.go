@@ -0,0 +1,51 @@
+//go:build envtest
+
+package v1
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("JsonServer scale webhook", func() {
+	var jsonServer *JsonServer
+
+	BeforeEach(func() {
+		MaxReplicas = 10
+		replicas := int32(1)
+		jsonServer = &JsonServer{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-scale", Namespace: "default"},
+			Spec:       JsonServerSpec{Replicas: &replicas, JsonConfig: `{"people": []}`},
+		}
+		Expect(k8sClient.Create(ctx, jsonServer)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		MaxReplicas = 0
+		Expect(k8sClient.Delete(ctx, jsonServer)).To(Succeed())
+	})
+
+	scaleTo := func(replicas int32) error {
+		scale := &autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: replicas}}
+		return k8sClient.SubResource("scale").Update(ctx, jsonServer, client.WithSubResourceBody(scale))
+	}
+
+	It("allows valid replicas", func() {
+		Expect(scaleTo(3)).To(Succeed())
+		Expect(scaleTo(0)).To(Succeed())
+	})
+
+	It("denies negative replicas", func() {
+		// the API server may reject it before the webhook is called
+		Expect(scaleTo(-1)).NotTo(Succeed())
+	})
+
+	It("denies replicas above the limit", func() {
+		err := scaleTo(11)
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(ContainSubstring("replicas must be less or equal 10"))
+	})
+})
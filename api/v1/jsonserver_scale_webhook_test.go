@@ -0,0 +1,77 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+	"testing"
+)
+
+func Test_validateReplicas(t *testing.T) {
+	defer func() { MaxReplicas = 0 }()
+	assert.Empty(t, validateReplicas(0))
+	assert.Empty(t, validateReplicas(100))
+	assert.Equal(t, []string{"replicas must be gather or equal 0"}, validateReplicas(-1))
+
+	MaxReplicas = 10
+	assert.Empty(t, validateReplicas(10))
+	assert.Equal(t, []string{"replicas must be less or equal 10"}, validateReplicas(11))
+}
+
+func TestScaleValidator_Handle(t *testing.T) {
+	defer func() {
+		apiReader = nil
+		MaxReplicas = 0
+	}()
+	MaxReplicas = 10
+	scheme := runtime.NewScheme()
+	assert.NoError(t, AddToScheme(scheme))
+	assert.NoError(t, autoscalingv1.AddToScheme(scheme))
+	maxReplicas := int32(4)
+	apiReader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&JsonServerQuota{ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "team-a"}, Spec: JsonServerQuotaSpec{MaxTotalReplicas: &maxReplicas}},
+		newQuotaTestJsonServer("app-one", 1, "{}"),
+	).Build()
+	validator := &scaleValidator{decoder: admission.NewDecoder(scheme)}
+
+	tests := []struct {
+		name     string
+		replicas int32
+		allowed  bool
+		message  string
+	}{
+		{name: "valid", replicas: 3, allowed: true},
+		{name: "scale to zero", replicas: 0, allowed: true},
+		{name: "negative", replicas: -1, message: "validation issues: replicas must be gather or equal 0"},
+		{name: "above limit of the operator", replicas: 11, message: "validation issues: replicas must be less or equal 10"},
+		{name: "above quota", replicas: 5, message: "validation issues: exceeded JsonServerQuota quota: maxTotalReplicas 4 (requested 5)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scale := &autoscalingv1.Scale{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "autoscaling/v1", Kind: "Scale"},
+				ObjectMeta: metav1.ObjectMeta{Name: "app-one", Namespace: "team-a"},
+				Spec:       autoscalingv1.ScaleSpec{Replicas: tt.replicas},
+			}
+			raw, err := json.Marshal(scale)
+			assert.NoError(t, err)
+			resp := validator.Handle(context.Background(), admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+				Name:        "app-one",
+				Namespace:   "team-a",
+				Operation:   admissionv1.Update,
+				SubResource: "scale",
+				Object:      runtime.RawExtension{Raw: raw},
+			}})
+			assert.Equal(t, tt.allowed, resp.Allowed)
+			if !tt.allowed {
+				assert.Equal(t, tt.message, resp.Result.Message)
+			}
+		})
+	}
+}
@@ -17,7 +17,10 @@ limitations under the License.
 package v1
 
 import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 type SyncState string
@@ -26,8 +29,27 @@ const (
 	SyncStateNotSynced = "NotSynced"
 	SyncStateSynced    = "Synced"
 	SyncStateError     = "Error"
+	SyncStatePaused    = "Paused"
 )
 
+const (
+	// ConditionCertificateReady reports if the certificate served with TLS is ready
+	ConditionCertificateReady = "CertificateReady"
+	// ConditionPaused reports if reconciliation of a JsonServer is paused by PausedAnnotation
+	ConditionPaused = "Paused"
+	// ConditionConflict reports existing resources that cannot be used as children of a JsonServer
+	ConditionConflict = "Conflict"
+)
+
+// IdleReplicasAnnotation keeps replicas of a JsonServer scaled to zero when idle, the activator restores them
+const IdleReplicasAnnotation = "jsonserver.example.com/idle-replicas"
+
+// DryRunAnnotation set to "true" makes the operator only plan changes of the JsonServer, "false" overrides --dry-run of the operator
+const DryRunAnnotation = "jsonserver.example.com/dry-run"
+
+// PausedAnnotation set to "true" stops the operator from changing children of the JsonServer, e.g. to edit the Deployment by hand
+const PausedAnnotation = "jsonserver.example.com/paused"
+
 // EDIT THIS FILE! THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
@@ -37,6 +59,286 @@ type JsonServerSpec struct {
 	Replicas *int32 `json:"replicas,omitempty"`
 	// valid json
 	JsonConfig string `json:"jsonConfig"`
+	// jsonConfig is a Go text/template rendered by the operator before it is served
+	Templated bool `json:"templated,omitempty"`
+	// Values available in a templated jsonConfig as {{ .Params.name }}
+	Parameters map[string]string `json:"parameters,omitempty"`
+	// Name of JsonServerClass providing defaults, the default class is used if empty
+	ClassName *string `json:"className,omitempty"`
+	// Overrides settings of the class
+	ServerSettings `json:",inline"`
+	// Scale replicas with HorizontalPodAutoscaler, replicas are managed by the autoscaler when set
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+	// PodDisruptionBudget created when there is more than one replica, maxUnavailable=1 by default
+	DisruptionBudget *DisruptionBudgetSpec `json:"disruptionBudget,omitempty"`
+	// Restrict who can call json-server with a NetworkPolicy
+	Access *AccessSpec `json:"access,omitempty"`
+	// Authentication enforced by a sidecar injected into json-server pods
+	Auth *AuthSpec `json:"auth,omitempty"`
+	// Restrict methods allowed on paths. Requests to a path matched by any rule are allowed only
+	// if a rule matching the path and the caller allows the method, other paths are not restricted.
+	AccessRules []AccessRule `json:"accessRules,omitempty"`
+	// Serve HTTPS, the certificate is terminated by a sidecar injected into json-server pods
+	TLS *TLSSpec `json:"tls,omitempty"`
+	// CORS policy and response headers applied by a sidecar injected into json-server pods
+	HTTP *HTTPSpec `json:"http,omitempty"`
+	// Protect the JsonServer and its data against accidental deletion
+	DeletionProtection *DeletionProtectionSpec `json:"deletionProtection,omitempty"`
+	// Delete the JsonServer this many seconds after it was created
+	// +kubebuilder:validation:Minimum=1
+	TTLSecondsAfterCreation *int32 `json:"ttlSecondsAfterCreation,omitempty"`
+	// Delete the JsonServer when it has not received requests for this many minutes,
+	// requests are counted by a sidecar injected into json-server pods
+	// +kubebuilder:validation:Minimum=1
+	IdleTTLMinutes *int32 `json:"idleTTLMinutes,omitempty"`
+	// Scale json-server to zero when it is idle, the first request scales it up again
+	Idle *IdleSpec `json:"idle,omitempty"`
+	// Set replicas at scheduled times, e.g. scale up on working days and down at night.
+	// Replicas changed manually stay until the next scheduled change.
+	// +listType=map
+	// +listMapKey=name
+	Schedules []ScalingSchedule `json:"schedules,omitempty"`
+	// Export request metrics of json-server in Prometheus format, served by a sidecar injected into json-server pods
+	Metrics *MetricsSpec `json:"metrics,omitempty"`
+	// Structured access logs written by a sidecar injected into json-server pods, which replace logs of json-server
+	Logging *LoggingSpec `json:"logging,omitempty"`
+	// What to do with existing resources named like the JsonServer that it does not own, e.g. left by a Helm release:
+	// fail (default) reports a Conflict, adopt takes over resources without a controller,
+	// rename names children differently if the name is taken before they are created
+	AdoptionPolicy AdoptionPolicy `json:"adoptionPolicy,omitempty"`
+}
+
+// AdoptionPolicy defines handling of existing resources not owned by a JsonServer
+// +kubebuilder:validation:Enum=adopt;fail;rename
+type AdoptionPolicy string
+
+const (
+	AdoptionPolicyAdopt  AdoptionPolicy = "adopt"
+	AdoptionPolicyFail   AdoptionPolicy = "fail"
+	AdoptionPolicyRename AdoptionPolicy = "rename"
+)
+
+// LogFormat is the format of access log lines
+// +kubebuilder:validation:Enum=json;text
+type LogFormat string
+
+const (
+	LogFormatJSON LogFormat = "json"
+	LogFormatText LogFormat = "text"
+)
+
+// LoggingSpec defines access logs of json-server
+type LoggingSpec struct {
+	// Format of log lines, json (default) or text with key=value pairs
+	Format LogFormat `json:"format,omitempty"`
+	// Percent of requests logged, 100 by default. Requests failed with 5xx status are always logged
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=100
+	SamplePercent *int32 `json:"samplePercent,omitempty"`
+	// Log request and response bodies up to this number of bytes, bodies are not logged if 0
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65536
+	MaxBodyBytes int32 `json:"maxBodyBytes,omitempty"`
+	// Names of JSON fields of bodies and query parameters whose values are replaced by [REDACTED], matched case-insensitively.
+	// Headers are not logged
+	RedactFields []string `json:"redactFields,omitempty"`
+}
+
+// MetricsSpec exports request counters and latencies per collection, method and status on the metrics port
+type MetricsSpec struct {
+	// Create a Prometheus Operator ServiceMonitor scraping the metrics port, requires the ServiceMonitor CRD in the cluster
+	ServiceMonitor *ServiceMonitorSpec `json:"serviceMonitor,omitempty"`
+}
+
+type ServiceMonitorSpec struct {
+	// Scrape interval, e.g. 30s, the default of Prometheus if empty
+	// +kubebuilder:validation:Pattern=`^([0-9]+(ms|s|m|h))+$`
+	Interval string `json:"interval,omitempty"`
+	// Labels of the ServiceMonitor, used by Prometheus to select ServiceMonitors
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// ScalingSchedule sets replicas of JsonServer when its cron expression fires
+type ScalingSchedule struct {
+	// Name of the schedule, reported in status
+	Name string `json:"name"`
+	// Cron expression with 5 fields (minute hour day-of-month month day-of-week), e.g. "0 8 * * 1-5"
+	Schedule string `json:"schedule"`
+	// IANA time zone of the schedule, e.g. Europe/Warsaw, UTC by default
+	TimeZone string `json:"timeZone,omitempty"`
+	// Replicas set when the schedule fires
+	// +kubebuilder:validation:Minimum=0
+	Replicas int32 `json:"replicas"`
+}
+
+// IdleSpec scales json-server to zero replicas when there are no requests,
+// requests to a scaled down JsonServer are held by the activator of the operator until a pod is ready
+type IdleSpec struct {
+	// Scale to zero after no requests for this duration, e.g. 30m
+	IdleAfter metav1.Duration `json:"idleAfter"`
+}
+
+// DeletionProtectionSpec protects a JsonServer against accidental deletion
+type DeletionProtectionSpec struct {
+	// Reject deletion of the JsonServer, it has to be set to false before the JsonServer can be deleted
+	Enabled bool `json:"enabled,omitempty"`
+	// Keep data of json-server in ConfigMap <name>-snapshot when the JsonServer is deleted,
+	// the ConfigMap is not owned by the JsonServer and stays until it is deleted manually
+	Snapshot bool `json:"snapshot,omitempty"`
+}
+
+// HTTPSpec defines headers of responses
+type HTTPSpec struct {
+	// CORS policy replacing the default one of json-server, which allows any origin
+	CORS *CORSSpec `json:"cors,omitempty"`
+	// Static response headers, when more routes match a path the later one wins
+	Headers []RouteHeaders `json:"headers,omitempty"`
+}
+
+// CORSSpec defines Cross-Origin Resource Sharing policy
+type CORSSpec struct {
+	// Origins like https://app.example.com or * for any origin
+	// +kubebuilder:validation:MinItems=1
+	AllowedOrigins []string `json:"allowedOrigins"`
+	// Methods allowed in preflight responses, GET, HEAD, POST, PUT, PATCH and DELETE if empty
+	AllowedMethods []HTTPMethod `json:"allowedMethods,omitempty"`
+	// Request headers allowed in preflight responses, all requested headers if empty
+	AllowedHeaders []string `json:"allowedHeaders,omitempty"`
+	// Response headers readable by browsers
+	ExposedHeaders []string `json:"exposedHeaders,omitempty"`
+	// Allow cookies and credentials, cannot be used with * origin
+	AllowCredentials bool `json:"allowCredentials,omitempty"`
+	// How long preflight responses can be cached
+	// +kubebuilder:validation:Minimum=0
+	MaxAgeSeconds *int32 `json:"maxAgeSeconds,omitempty"`
+}
+
+// RouteHeaders are headers of responses to requests with paths matching the pattern
+type RouteHeaders struct {
+	// Path pattern with path.Match syntax, matches also paths below the matched path, all paths if empty
+	Path string `json:"path,omitempty"`
+	// Header names and values
+	Headers map[string]string `json:"headers"`
+}
+
+// TLSSpec defines the certificate of json-server, exactly one of secretName and issuerRef is required
+type TLSSpec struct {
+	// Name of an existing Secret of type kubernetes.io/tls
+	SecretName string `json:"secretName,omitempty"`
+	// cert-manager issuer of a Certificate created for DNS names of the Service
+	IssuerRef *IssuerReference `json:"issuerRef,omitempty"`
+}
+
+// IssuerReference is a reference to a cert-manager issuer
+type IssuerReference struct {
+	Name string `json:"name"`
+	// +kubebuilder:validation:Enum=Issuer;ClusterIssuer
+	// +kubebuilder:default=Issuer
+	Kind string `json:"kind,omitempty"`
+	// API group of the issuer, cert-manager.io by default
+	Group string `json:"group,omitempty"`
+}
+
+// HTTPMethod is a method of an HTTP request
+// +kubebuilder:validation:Enum=GET;HEAD;POST;PUT;PATCH;DELETE;OPTIONS
+type HTTPMethod string
+
+// AccessRule allows methods on matching paths to matching callers, exactly one of collection and path is required
+type AccessRule struct {
+	// Top-level key of jsonConfig, matches /<collection> and paths below it
+	Collection string `json:"collection,omitempty"`
+	// Path pattern with path.Match syntax like /posts/*, matches also paths below the matched path
+	Path string `json:"path,omitempty"`
+	// Allowed methods, all methods if empty
+	Methods []HTTPMethod `json:"methods,omitempty"`
+	// Principals the rule applies to
+	Principals []string `json:"principals,omitempty"`
+	// Roles the rule applies to, the rule applies to all callers if principals and roles are empty
+	Roles []string `json:"roles,omitempty"`
+}
+
+// AuthSpec defines accepted credentials, a request is accepted if any of them matches
+type AuthSpec struct {
+	// Static bearer tokens stored in Secrets
+	BearerTokens []BearerTokenAuth `json:"bearerTokens,omitempty"`
+	// Users stored in Secrets of type kubernetes.io/basic-auth
+	BasicAuth []BasicAuthUser `json:"basicAuth,omitempty"`
+	// Bearer tokens that are JWTs signed with a key from JWKS
+	JWT *JWTAuth `json:"jwt,omitempty"`
+	// Accept GET and HEAD requests without credentials
+	AnonymousRead bool `json:"anonymousRead,omitempty"`
+}
+
+// BearerTokenAuth is a static token
+type BearerTokenAuth struct {
+	// Secret key with the token
+	SecretKeyRef corev1.SecretKeySelector `json:"secretKeyRef"`
+	// Name of the caller authenticated with the token
+	Principal string `json:"principal"`
+	// Roles of the caller
+	Roles []string `json:"roles,omitempty"`
+}
+
+// BasicAuthUser is a user with credentials in a Secret
+type BasicAuthUser struct {
+	// Name of a Secret with username and password keys
+	SecretName string `json:"secretName"`
+	// Roles of the user
+	Roles []string `json:"roles,omitempty"`
+}
+
+// JWTAuth defines validation of JWTs, tokens without exp claim are rejected
+type JWTAuth struct {
+	// ConfigMap key with JSON Web Key Set, RS* and ES* algorithms are supported
+	JWKS corev1.ConfigMapKeySelector `json:"jwks"`
+	// Required iss claim
+	Issuer string `json:"issuer,omitempty"`
+	// Accepted aud claims
+	Audiences []string `json:"audiences,omitempty"`
+	// Claim with a list of roles, "roles" by default
+	RolesClaim string `json:"rolesClaim,omitempty"`
+}
+
+// AccessSpec defines NetworkPolicy created for JsonServer
+type AccessSpec struct {
+	// Peers allowed to call json-server
+	AllowFrom []AccessPeer `json:"allowFrom,omitempty"`
+	// Deny traffic from the namespace of JsonServer unless matched by allowFrom. With empty allowFrom all traffic is denied.
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+}
+
+// AccessPeer is a source of allowed traffic, either selectors or cidr
+type AccessPeer struct {
+	// Namespaces allowed to call json-server, the namespace of JsonServer if only podSelector is set
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// Pods allowed to call json-server
+	PodSelector *metav1.LabelSelector `json:"podSelector,omitempty"`
+	// IP range allowed to call json-server, e.g. 10.0.0.0/16
+	CIDR string `json:"cidr,omitempty"`
+	// IP ranges within cidr that are not allowed
+	Except []string `json:"except,omitempty"`
+}
+
+// DisruptionBudgetSpec overrides PodDisruptionBudget limits, only one of them can be set
+type DisruptionBudgetSpec struct {
+	// Number or percentage of pods that must be available during eviction
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+	// Number or percentage of pods that can be unavailable during eviction
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// AutoscalingSpec defines HorizontalPodAutoscaler created for JsonServer
+type AutoscalingSpec struct {
+	// Lower limit of replicas, 1 by default
+	// +kubebuilder:validation:Minimum=1
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// Upper limit of replicas
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// Target average CPU utilization (percent of requested CPU), 80 if no metrics are defined
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+	// Custom metrics used in addition to CPU utilization
+	Metrics []autoscalingv2.MetricSpec `json:"metrics,omitempty"`
 }
 
 // JsonServerStatus defines the observed state of JsonServer
@@ -45,12 +347,44 @@ type JsonServerStatus struct {
 	SyncMessage string    `json:"message,omitempty"`
 	Replicas    int32     `json:"replicas"`
 	Selector    string    `json:"selector,omitempty"`
+	// Requests rejected by authentication since the pods have started
+	UnauthenticatedRequests int64 `json:"unauthenticatedRequests,omitempty"`
+	// Conditions of JsonServer, CertificateReady is reported when TLS is enabled, Paused when reconciliation is paused
+	// and Conflict when existing resources cannot be used as children
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Time of the last request served by json-server, tracked when idleTTLMinutes is set
+	LastRequestTime *metav1.Time `json:"lastRequestTime,omitempty"`
+	// Time when the JsonServer is deleted by ttlSecondsAfterCreation or idleTTLMinutes
+	ExpirationTime *metav1.Time `json:"expirationTime,omitempty"`
+	// Remaining lifetime of the JsonServer in hours, or in minutes in the last hour
+	ExpiresIn string `json:"expiresIn,omitempty"`
+	// No pod is ready and requests are routed to the activator, set when idle is configured
+	Idle bool `json:"idle,omitempty"`
+	// Last change of replicas by schedules
+	LastScheduledScaling *ScheduledScaling `json:"lastScheduledScaling,omitempty"`
+	// Next change of replicas by schedules
+	NextScheduledScaling *ScheduledScaling `json:"nextScheduledScaling,omitempty"`
+	// Changes the operator would make in dry-run mode, prefixed by their reasons like Update-Deployment
+	PlannedActions []string `json:"plannedActions,omitempty"`
+	// Name of children when it differs from the name of the JsonServer, chosen by adoptionPolicy rename
+	ChildName string `json:"childName,omitempty"`
+}
+
+// ScheduledScaling is a change of replicas by a schedule
+type ScheduledScaling struct {
+	// Name of the schedule
+	Schedule string      `json:"schedule"`
+	Time     metav1.Time `json:"time"`
+	Replicas int32       `json:"replicas"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
 // +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
 // +kubebuilder:printcolumn:name="Status",type=string,JSONPath=`.status.state`
+// +kubebuilder:printcolumn:name="Expires In",type=string,JSONPath=`.status.expiresIn`
 // JsonServer is the Schema for the jsonservers API
 type JsonServer struct {
 	metav1.TypeMeta   `json:",inline"`
@@ -18,27 +18,44 @@ package v1
 
 import (
 	"fmt"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
 	"strings"
 )
 
-const requiredPrefix = "app-"
+// apiReader reads objects needed by validation without caching them, nil disables checks that need it
+var apiReader client.Reader
+
+// MaxReplicas is the maximum number of replicas of a JsonServer, 0 means no limit
+var MaxReplicas int32
+
+// WatchedNamespaces are namespaces watched by the operator, all namespaces if empty
+var WatchedNamespaces []string
+
+// WatchedSelector selects JsonServers reconciled by the operator, all if nil
+var WatchedSelector labels.Selector
 
 // log is for logging in this package.
 var jsonserverlog = logf.Log.WithName("jsonserver-resource")
 
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get
+//+kubebuilder:rbac:groups=example.com,resources=jsonserverquotas,verbs=get;list;watch
+
 func (r *JsonServer) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	apiReader = mgr.GetAPIReader()
+	setupScaleWebhookWithManager(mgr)
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
 }
 
 // +kubebuilder:webhook:path=/mutate-example-com-v1-jsonserver,mutating=true,failurePolicy=fail,sideEffects=None,groups=example.com,resources=jsonservers,verbs=create;update,versions=v1,name=mjsonserver.kb.io,admissionReviewVersions=v1
-// +kubebuilder:webhook:path=/validate-example-com-v1-jsonserver,mutating=false,failurePolicy=fail,sideEffects=None,groups=example.com,resources=jsonservers,verbs=create;update,versions=v1,name=vjsonserver.kb.io,admissionReviewVersions=v1
+
 var defaultReplicas int32 = 2
 var _ webhook.Defaulter = &JsonServer{}
 
@@ -50,38 +67,143 @@ func (r *JsonServer) Default() {
 	}
 }
 
-// +kubebuilder:webhook:path=/validate-example-com-v1-jsonserver,mutating=false,failurePolicy=fail,sideEffects=None,groups=example.com,resources=jsonservers,verbs=create;update,versions=v1,name=vjsonserver.kb.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-example-com-v1-jsonserver,mutating=false,failurePolicy=fail,sideEffects=None,groups=example.com,resources=jsonservers,verbs=create;update;delete,versions=v1,name=vjsonserver.kb.io,admissionReviewVersions=v1
+
 var _ webhook.Validator = &JsonServer{}
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type
 func (r *JsonServer) ValidateCreate() (admission.Warnings, error) {
 	jsonserverlog.Info("webhook validate create", "name", r.Name)
-	return r.validate()
+	return r.validate(nil)
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
 func (r *JsonServer) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
 	jsonserverlog.Info("webhook validate update", "name", r.Name)
-	return r.validate()
+	oldJsonServer, ok := old.(*JsonServer)
+	if !ok {
+		return nil, fmt.Errorf("expected a JsonServer but got %T", old)
+	}
+	if r.DeletionTimestamp != nil {
+		// finalizers of a deleted JsonServer must be removable even if it is no longer valid
+		return nil, nil
+	}
+	return r.validate(oldJsonServer)
 }
 
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type
 func (r *JsonServer) ValidateDelete() (admission.Warnings, error) {
-	// nothing here
+	jsonserverlog.Info("webhook validate delete", "name", r.Name)
+	if p := r.Spec.DeletionProtection; p != nil && p.Enabled {
+		return nil, fmt.Errorf("deletion protection is enabled, set spec.deletionProtection.enabled to false to delete the JsonServer")
+	}
 	return nil, nil
 }
-func (r *JsonServer) validate() (warnings admission.Warnings, err error) {
-	warnings = admission.Warnings{}
+
+// validateReplicas checks replicas set in spec or by the scale subresource
+func validateReplicas(replicas int32) []string {
 	validationErrors := make([]string, 0)
-	if r.Spec.Replicas != nil && *r.Spec.Replicas < 0 {
+	if replicas < 0 {
 		validationErrors = append(validationErrors, "replicas must be gather or equal 0")
 	}
-	if !strings.HasPrefix(r.Name, requiredPrefix) {
-		validationErrors = append(validationErrors, fmt.Sprintf("resource name must start with '%s'", requiredPrefix))
+	if MaxReplicas > 0 && replicas > MaxReplicas {
+		validationErrors = append(validationErrors, fmt.Sprintf("replicas must be less or equal %d", MaxReplicas))
+	}
+	return validationErrors
+}
+
+// validate checks JsonServer, old is nil when it is created
+func (r *JsonServer) validate(old *JsonServer) (warnings admission.Warnings, err error) {
+	warnings = admission.Warnings{}
+	validationErrors := make([]string, 0)
+	if r.Spec.Replicas != nil {
+		validationErrors = append(validationErrors, validateReplicas(*r.Spec.Replicas)...)
+	}
+	if as := r.Spec.Autoscaling; as != nil {
+		if as.MaxReplicas < 1 {
+			validationErrors = append(validationErrors, "autoscaling.maxReplicas must be greater or equal 1")
+		}
+		if MaxReplicas > 0 && as.MaxReplicas > MaxReplicas {
+			validationErrors = append(validationErrors, fmt.Sprintf("autoscaling.maxReplicas must be less or equal %d", MaxReplicas))
+		}
+		if as.MinReplicas != nil && (*as.MinReplicas < 1 || *as.MinReplicas > as.MaxReplicas) {
+			validationErrors = append(validationErrors, "autoscaling.minReplicas must be between 1 and autoscaling.maxReplicas")
+		}
+		if as.TargetCPUUtilizationPercentage != nil && *as.TargetCPUUtilizationPercentage < 1 {
+			validationErrors = append(validationErrors, "autoscaling.targetCPUUtilizationPercentage must be greater or equal 1")
+		}
+	}
+	if db := r.Spec.DisruptionBudget; db != nil {
+		if db.MinAvailable != nil && db.MaxUnavailable != nil {
+			validationErrors = append(validationErrors, "only one of disruptionBudget.minAvailable and disruptionBudget.maxUnavailable can be set")
+		}
+		if err := validateIntOrPercent(db.MinAvailable); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("disruptionBudget.minAvailable %s", err))
+		}
+		if err := validateIntOrPercent(db.MaxUnavailable); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("disruptionBudget.maxUnavailable %s", err))
+		}
 	}
-	if jsonErr := validateJson(r.Spec.JsonConfig); jsonErr != nil {
-		validationErrors = append(validationErrors, fmt.Sprintf("invalid jsonConfig - %s", jsonErr))
+	if r.Spec.Access != nil {
+		for i, peer := range r.Spec.Access.AllowFrom {
+			if err := validateAccessPeer(peer); err != nil {
+				validationErrors = append(validationErrors, fmt.Sprintf("access.allowFrom[%d] %s", i, err))
+			}
+		}
 	}
+	if r.Spec.Auth != nil {
+		validationErrors = append(validationErrors, validateAuth(r.Spec.Auth)...)
+	}
+	if r.Spec.TLS != nil {
+		validationErrors = append(validationErrors, validateTLS(r.Spec.TLS)...)
+	}
+	if r.Spec.HTTP != nil {
+		validationErrors = append(validationErrors, validateHTTP(r.Spec.HTTP)...)
+	}
+	if r.Spec.TTLSecondsAfterCreation != nil && *r.Spec.TTLSecondsAfterCreation < 1 {
+		validationErrors = append(validationErrors, "ttlSecondsAfterCreation must be greater or equal 1")
+	}
+	if r.Spec.IdleTTLMinutes != nil && *r.Spec.IdleTTLMinutes < 1 {
+		validationErrors = append(validationErrors, "idleTTLMinutes must be greater or equal 1")
+	}
+	if (r.Spec.TTLSecondsAfterCreation != nil || r.Spec.IdleTTLMinutes != nil) && r.Spec.DeletionProtection != nil && r.Spec.DeletionProtection.Enabled {
+		validationErrors = append(validationErrors, "ttlSecondsAfterCreation and idleTTLMinutes cannot be used with deletionProtection.enabled")
+	}
+	if r.Spec.Idle != nil {
+		validationErrors = append(validationErrors, validateIdle(r)...)
+	}
+	if len(r.Spec.Schedules) > 0 {
+		validationErrors = append(validationErrors, validateSchedules(r)...)
+	}
+	// a naming policy changed later does not block updates of existing JsonServers
+	if old == nil || old.Name != r.Name {
+		if policy, err := namingPolicyFor(r.Namespace); err != nil {
+			validationErrors = append(validationErrors, err.Error())
+		} else if err := policy.Validate(r.Name); err != nil {
+			if policy != DefaultNamingPolicy {
+				err = fmt.Errorf("%s (naming policy of namespace %s)", err, r.Namespace)
+			}
+			validationErrors = append(validationErrors, err.Error())
+		}
+	}
+	if jsonConfig, tplErr := r.RenderedJsonConfig(); tplErr != nil {
+		validationErrors = append(validationErrors, fmt.Sprintf("invalid jsonConfig template - %s", tplErr))
+	} else {
+		if jsonErr := validateJson(jsonConfig); jsonErr != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("invalid jsonConfig - %s", jsonErr))
+		}
+		validationErrors = append(validationErrors, validateAccessRules(r.Spec.AccessRules, r.Spec.Auth, jsonConfig)...)
+	}
+	if quotaUsageGrows(old, r) {
+		if err := checkQuotas(r); err != nil {
+			validationErrors = append(validationErrors, err.Error())
+		}
+	}
+	if old != nil {
+		validationErrors = append(validationErrors, r.validateImmutableFields(old)...)
+		warnings = append(warnings, r.updateWarnings(old)...)
+	}
+	warnings = append(warnings, r.watchWarnings()...)
 	if len(validationErrors) > 0 {
 		jsonserverlog.Info("validation issues", "name", r.Name, "issues", strings.Join(validationErrors, ";"))
 		return warnings, fmt.Errorf("validation issues: %s", strings.Join(validationErrors, "; "))
@@ -90,3 +212,21 @@ func (r *JsonServer) validate() (warnings admission.Warnings, err error) {
 		return warnings, nil
 	}
 }
+
+// watchWarnings warns about JsonServers the operator does not reconcile, as they are outside of watched namespaces or labels.
+func (r *JsonServer) watchWarnings() admission.Warnings {
+	warnings := admission.Warnings{}
+	if len(WatchedNamespaces) > 0 {
+		watched := false
+		for _, ns := range WatchedNamespaces {
+			watched = watched || ns == r.Namespace
+		}
+		if !watched {
+			warnings = append(warnings, fmt.Sprintf("namespace %s is not watched by the operator (watched: %s), the JsonServer will not be reconciled", r.Namespace, strings.Join(WatchedNamespaces, ", ")))
+		}
+	}
+	if WatchedSelector != nil && !WatchedSelector.Matches(labels.Set(r.Labels)) {
+		warnings = append(warnings, fmt.Sprintf("labels do not match '%s' watched by the operator, the JsonServer will not be reconciled", WatchedSelector))
+	}
+	return warnings
+}
@@ -0,0 +1,52 @@
+package v1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+	"testing"
+)
+
+func TestJsonServer_watchWarnings(t *testing.T) {
+	defer func() {
+		WatchedNamespaces = nil
+		WatchedSelector = nil
+	}()
+	jsonServer := &JsonServer{}
+	jsonServer.Namespace = "team-a"
+	jsonServer.Labels = map[string]string{"shard": "1"}
+	assert.Empty(t, jsonServer.watchWarnings())
+
+	WatchedNamespaces = []string{"team-a", "team-b"}
+	WatchedSelector = labels.SelectorFromSet(labels.Set{"shard": "1"})
+	assert.Empty(t, jsonServer.watchWarnings())
+
+	jsonServer.Namespace = "team-c"
+	jsonServer.Labels = nil
+	assert.Len(t, jsonServer.watchWarnings(), 2)
+}
+
+func TestJsonServer_ValidateDelete(t *testing.T) {
+	jsonServer := &JsonServer{}
+	_, err := jsonServer.ValidateDelete()
+	assert.NoError(t, err)
+
+	jsonServer.Spec.DeletionProtection = &DeletionProtectionSpec{Snapshot: true}
+	_, err = jsonServer.ValidateDelete()
+	assert.NoError(t, err)
+
+	jsonServer.Spec.DeletionProtection.Enabled = true
+	_, err = jsonServer.ValidateDelete()
+	assert.EqualError(t, err, "deletion protection is enabled, set spec.deletionProtection.enabled to false to delete the JsonServer")
+}
+
+func TestJsonServer_ValidateCreate_ttl(t *testing.T) {
+	ttl := int32(3600)
+	jsonServer := &JsonServer{Spec: JsonServerSpec{JsonConfig: "{}", TTLSecondsAfterCreation: &ttl}}
+	jsonServer.Name = "app-pr-12"
+	_, err := jsonServer.ValidateCreate()
+	assert.NoError(t, err)
+
+	jsonServer.Spec.DeletionProtection = &DeletionProtectionSpec{Enabled: true}
+	_, err = jsonServer.ValidateCreate()
+	assert.EqualError(t, err, "validation issues: ttlSecondsAfterCreation and idleTTLMinutes cannot be used with deletionProtection.enabled")
+}
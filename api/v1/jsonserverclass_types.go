@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultClassAnnotation marks the JsonServerClass used by JsonServers without spec.className
+const DefaultClassAnnotation = "jsonserverclass.example.com/is-default-class"
+
+// ServerSettings are settings of json-server pods and service that can be defined by JsonServerClass and overridden by JsonServer
+type ServerSettings struct {
+	// Container image of json-server
+	Image string `json:"image,omitempty"`
+	// Compute resources of json-server container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+	// Liveness probe of json-server container
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+	// Readiness probe of json-server container
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+	// Service settings
+	Service *ServiceSettings `json:"service,omitempty"`
+}
+
+// ServiceSettings defines how json-server is exposed
+type ServiceSettings struct {
+	// Type of the service, ClusterIP by default
+	Type corev1.ServiceType `json:"type,omitempty"`
+	// Annotations added to the service
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// JsonServerClassSpec defines defaults shared by JsonServers referencing the class
+type JsonServerClassSpec struct {
+	ServerSettings `json:",inline"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// JsonServerClass is the Schema for the jsonserverclasses API
+type JsonServerClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec JsonServerClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// JsonServerClassList contains a list of JsonServerClass
+type JsonServerClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JsonServerClass `json:"items"`
+}
+
+// IsDefault returns true if the class is marked with DefaultClassAnnotation
+func (c *JsonServerClass) IsDefault() bool {
+	return c.Annotations[DefaultClassAnnotation] == "true"
+}
+
+func init() {
+	SchemeBuilder.Register(&JsonServerClass{}, &JsonServerClassList{})
+}
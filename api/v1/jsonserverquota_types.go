@@ -0,0 +1,75 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// JsonServerQuotaSpec defines limits of JsonServers in the namespace of the quota, empty limits are not enforced
+type JsonServerQuotaSpec struct {
+	// Maximum number of JsonServers
+	// +kubebuilder:validation:Minimum=0
+	MaxJsonServers *int32 `json:"maxJsonServers,omitempty"`
+	// Maximum sum of replicas of JsonServers, autoscaling.maxReplicas is counted for autoscaled JsonServers
+	// +kubebuilder:validation:Minimum=0
+	MaxTotalReplicas *int32 `json:"maxTotalReplicas,omitempty"`
+	// Maximum sum of sizes of jsonConfig of JsonServers in bytes
+	// +kubebuilder:validation:Minimum=0
+	MaxJsonConfigBytes *int64 `json:"maxJsonConfigBytes,omitempty"`
+}
+
+// JsonServerQuotaUsage is usage of resources limited by a quota
+type JsonServerQuotaUsage struct {
+	JsonServers     int32 `json:"jsonServers"`
+	TotalReplicas   int32 `json:"totalReplicas"`
+	JsonConfigBytes int64 `json:"jsonConfigBytes"`
+}
+
+// JsonServerQuotaStatus defines the observed state of JsonServerQuota
+type JsonServerQuotaStatus struct {
+	// Current usage in the namespace
+	Used JsonServerQuotaUsage `json:"used,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="JsonServers",type=integer,JSONPath=`.status.used.jsonServers`
+// +kubebuilder:printcolumn:name="Max JsonServers",type=integer,JSONPath=`.spec.maxJsonServers`
+// +kubebuilder:printcolumn:name="Replicas",type=integer,JSONPath=`.status.used.totalReplicas`
+// +kubebuilder:printcolumn:name="Max Replicas",type=integer,JSONPath=`.spec.maxTotalReplicas`
+// JsonServerQuota is the Schema for the jsonserverquotas API
+type JsonServerQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   JsonServerQuotaSpec   `json:"spec,omitempty"`
+	Status JsonServerQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// JsonServerQuotaList contains a list of JsonServerQuota
+type JsonServerQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []JsonServerQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&JsonServerQuota{}, &JsonServerQuotaList{})
+}
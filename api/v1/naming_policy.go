@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"regexp"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Annotations of a Namespace overriding fields of the naming policy for JsonServers in the namespace
+const (
+	NamePrefixAnnotation    = "jsonserver.example.com/name-prefix"
+	NameSuffixAnnotation    = "jsonserver.example.com/name-suffix"
+	NamePatternAnnotation   = "jsonserver.example.com/name-pattern"
+	NameMaxLengthAnnotation = "jsonserver.example.com/name-max-length"
+)
+
+// NamingPolicy is a rule names of JsonServers must follow, empty fields are not checked
+// +kubebuilder:object:generate=false
+type NamingPolicy struct {
+	Prefix string
+	Suffix string
+	// Pattern is a regular expression the whole name must match
+	Pattern string
+	// MaxLength of the name, 0 means no limit
+	MaxLength int
+}
+
+// DefaultNamingPolicy applies to namespaces without overrides, configured by flags of the operator
+var DefaultNamingPolicy = NamingPolicy{Prefix: "app-"}
+
+// Check returns an error if the policy is invalid.
+func (p NamingPolicy) Check() error {
+	if p.MaxLength < 0 {
+		return fmt.Errorf("max length must be greater or equal 0")
+	}
+	if _, err := regexp.Compile(p.Pattern); err != nil {
+		return errors.Wrapf(err, "invalid pattern")
+	}
+	return nil
+}
+
+// Validate returns an error explaining the policy if the name does not follow it.
+func (p NamingPolicy) Validate(name string) error {
+	valid := strings.HasPrefix(name, p.Prefix) && strings.HasSuffix(name, p.Suffix) && (p.MaxLength == 0 || len(name) <= p.MaxLength)
+	if valid && p.Pattern != "" {
+		pattern, err := regexp.Compile("^(?:" + p.Pattern + ")$")
+		if err != nil {
+			return errors.Wrapf(err, "invalid naming policy pattern")
+		}
+		valid = pattern.MatchString(name)
+	}
+	if !valid {
+		return fmt.Errorf("resource name %s", p)
+	}
+	return nil
+}
+
+// String describes the policy, like "must start with 'app-' and have at most 30 characters".
+func (p NamingPolicy) String() string {
+	rules := make([]string, 0)
+	if p.Prefix != "" {
+		rules = append(rules, fmt.Sprintf("start with '%s'", p.Prefix))
+	}
+	if p.Suffix != "" {
+		rules = append(rules, fmt.Sprintf("end with '%s'", p.Suffix))
+	}
+	if p.Pattern != "" {
+		rules = append(rules, fmt.Sprintf("match '%s'", p.Pattern))
+	}
+	if p.MaxLength > 0 {
+		rules = append(rules, fmt.Sprintf("have at most %d characters", p.MaxLength))
+	}
+	if len(rules) == 0 {
+		return "is not restricted"
+	}
+	if len(rules) == 1 {
+		return "must " + rules[0]
+	}
+	return "must " + strings.Join(rules[:len(rules)-1], ", ") + " and " + rules[len(rules)-1]
+}
+
+// WithOverrides returns the policy with fields overridden by annotations of a Namespace.
+func (p NamingPolicy) WithOverrides(annotations map[string]string) (NamingPolicy, error) {
+	if value, ok := annotations[NamePrefixAnnotation]; ok {
+		p.Prefix = value
+	}
+	if value, ok := annotations[NameSuffixAnnotation]; ok {
+		p.Suffix = value
+	}
+	if value, ok := annotations[NamePatternAnnotation]; ok {
+		p.Pattern = value
+	}
+	if value, ok := annotations[NameMaxLengthAnnotation]; ok {
+		maxLength, err := strconv.Atoi(value)
+		if err != nil {
+			return p, errors.Wrapf(err, "invalid annotation %s", NameMaxLengthAnnotation)
+		}
+		p.MaxLength = maxLength
+	}
+	return p, p.Check()
+}
+
+// namingPolicyFor returns the naming policy of a namespace.
+func namingPolicyFor(namespace string) (NamingPolicy, error) {
+	if apiReader == nil || namespace == "" {
+		return DefaultNamingPolicy, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ns := &corev1.Namespace{}
+	if err := apiReader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		if k8errors.IsNotFound(err) {
+			return DefaultNamingPolicy, nil
+		}
+		return DefaultNamingPolicy, errors.Wrapf(err, "cannot read naming policy of namespace %s", namespace)
+	}
+	policy, err := DefaultNamingPolicy.WithOverrides(ns.Annotations)
+	if err != nil {
+		return DefaultNamingPolicy, errors.Wrapf(err, "invalid naming policy of namespace %s", namespace)
+	}
+	return policy, nil
+}
@@ -0,0 +1,56 @@
+package v1
+
+import (
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func TestNamingPolicy_Validate(t *testing.T) {
+	tests := []struct {
+		policy  NamingPolicy
+		name    string
+		wantErr string
+	}{
+		{policy: NamingPolicy{Prefix: "app-"}, name: "app-test"},
+		{policy: NamingPolicy{Prefix: "app-"}, name: "test", wantErr: "resource name must start with 'app-'"},
+		{policy: NamingPolicy{}, name: "test"},
+		{policy: NamingPolicy{Suffix: "-mock", MaxLength: 10}, name: "users-mock"},
+		{policy: NamingPolicy{Suffix: "-mock", MaxLength: 10}, name: "orders-mock", wantErr: "resource name must end with '-mock' and have at most 10 characters"},
+		{policy: NamingPolicy{Prefix: "team-", Pattern: "[a-z]+-[a-z]+"}, name: "team-users"},
+		{policy: NamingPolicy{Prefix: "team-", Pattern: "[a-z]+-[a-z]+"}, name: "team-users-1", wantErr: "resource name must start with 'team-' and match '[a-z]+-[a-z]+'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String()+" "+tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.name)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_namingPolicyFor(t *testing.T) {
+	defer func() { apiReader = nil }()
+	scheme := runtime.NewScheme()
+	assert.NoError(t, corev1.AddToScheme(scheme))
+	apiReader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: map[string]string{NamePrefixAnnotation: "", NameMaxLengthAnnotation: "20"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "invalid", Annotations: map[string]string{NamePatternAnnotation: "("}}},
+	).Build()
+
+	policy, err := namingPolicyFor("default")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultNamingPolicy, policy)
+	policy, err = namingPolicyFor("team-a")
+	assert.NoError(t, err)
+	assert.Equal(t, NamingPolicy{MaxLength: 20}, policy)
+	_, err = namingPolicyFor("invalid")
+	assert.Error(t, err)
+}
@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"context"
+	"fmt"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"time"
+)
+
+// QuotaReplicas returns replicas of the JsonServer counted by quotas, the maximum for autoscaled JsonServers.
+func (r *JsonServer) QuotaReplicas() int32 {
+	if r.Spec.Autoscaling != nil {
+		return r.Spec.Autoscaling.MaxReplicas
+	}
+	if r.Spec.Replicas != nil {
+		return *r.Spec.Replicas
+	}
+	return defaultReplicas
+}
+
+// QuotaUsage returns usage of JsonServers counted by quotas, JsonServers being deleted are not counted.
+func QuotaUsage(jsonServers []JsonServer) JsonServerQuotaUsage {
+	usage := JsonServerQuotaUsage{}
+	for i := range jsonServers {
+		if jsonServers[i].DeletionTimestamp != nil {
+			continue
+		}
+		usage.JsonServers++
+		usage.TotalReplicas += jsonServers[i].QuotaReplicas()
+		usage.JsonConfigBytes += int64(len(jsonServers[i].Spec.JsonConfig))
+	}
+	return usage
+}
+
+// Exceeded returns limits exceeded by the new usage, limits are enforced only if the usage grows
+// so a quota lowered below the current usage does not block other changes.
+func (q *JsonServerQuota) Exceeded(old JsonServerQuotaUsage, new JsonServerQuotaUsage) []string {
+	exceeded := make([]string, 0)
+	if max := q.Spec.MaxJsonServers; max != nil && new.JsonServers > *max && new.JsonServers > old.JsonServers {
+		exceeded = append(exceeded, fmt.Sprintf("maxJsonServers %d (requested %d)", *max, new.JsonServers))
+	}
+	if max := q.Spec.MaxTotalReplicas; max != nil && new.TotalReplicas > *max && new.TotalReplicas > old.TotalReplicas {
+		exceeded = append(exceeded, fmt.Sprintf("maxTotalReplicas %d (requested %d)", *max, new.TotalReplicas))
+	}
+	if max := q.Spec.MaxJsonConfigBytes; max != nil && new.JsonConfigBytes > *max && new.JsonConfigBytes > old.JsonConfigBytes {
+		exceeded = append(exceeded, fmt.Sprintf("maxJsonConfigBytes %d (requested %d)", *max, new.JsonConfigBytes))
+	}
+	return exceeded
+}
+
+// quotaUsageGrows reports if quotas are checked for the JsonServer, old is nil when it is created.
+// Updates of existing JsonServers are checked only if they request more replicas or a larger jsonConfig.
+func quotaUsageGrows(old *JsonServer, jsonServer *JsonServer) bool {
+	if old == nil || old.Name != jsonServer.Name {
+		return true
+	}
+	return jsonServer.QuotaReplicas() > old.QuotaReplicas() || len(jsonServer.Spec.JsonConfig) > len(old.Spec.JsonConfig)
+}
+
+// checkQuotas returns an error if creating or updating the JsonServer exceeds a JsonServerQuota of its namespace.
+func checkQuotas(jsonServer *JsonServer) error {
+	if apiReader == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	quotas := &JsonServerQuotaList{}
+	if err := apiReader.List(ctx, quotas, client.InNamespace(jsonServer.Namespace)); err != nil {
+		return errors.Wrapf(err, "cannot list quotas of namespace %s", jsonServer.Namespace)
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+	jsonServers := &JsonServerList{}
+	if err := apiReader.List(ctx, jsonServers, client.InNamespace(jsonServer.Namespace)); err != nil {
+		return errors.Wrapf(err, "cannot list JsonServers of namespace %s", jsonServer.Namespace)
+	}
+	oldUsage := QuotaUsage(jsonServers.Items)
+	others := make([]JsonServer, 0, len(jsonServers.Items)+1)
+	for _, js := range jsonServers.Items {
+		if js.Name != jsonServer.Name {
+			others = append(others, js)
+		}
+	}
+	newUsage := QuotaUsage(append(others, *jsonServer))
+	violations := make([]string, 0)
+	for i := range quotas.Items {
+		if exceeded := quotas.Items[i].Exceeded(oldUsage, newUsage); len(exceeded) > 0 {
+			violations = append(violations, fmt.Sprintf("exceeded JsonServerQuota %s: %s", quotas.Items[i].Name, strings.Join(exceeded, ", ")))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%s", strings.Join(violations, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func newQuotaTestJsonServer(name string, replicas int32, jsonConfig string) *JsonServer {
+	return &JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+		Spec:       JsonServerSpec{Replicas: &replicas, JsonConfig: jsonConfig},
+	}
+}
+
+func Test_checkQuotas(t *testing.T) {
+	defer func() { apiReader = nil }()
+	scheme := runtime.NewScheme()
+	assert.NoError(t, AddToScheme(scheme))
+	maxJsonServers := int32(2)
+	maxReplicas := int32(5)
+	maxBytes := int64(10)
+	autoscaled := newQuotaTestJsonServer("app-autoscaled", 1, "{}")
+	autoscaled.Spec.Autoscaling = &AutoscalingSpec{MaxReplicas: 3}
+	otherNamespace := newQuotaTestJsonServer("app-other", 1, "{}")
+	otherNamespace.Namespace = "team-b"
+	apiReader = fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&JsonServerQuota{ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "team-a"}, Spec: JsonServerQuotaSpec{
+			MaxJsonServers: &maxJsonServers, MaxTotalReplicas: &maxReplicas, MaxJsonConfigBytes: &maxBytes,
+		}},
+		newQuotaTestJsonServer("app-one", 1, "{}"),
+		autoscaled,
+		otherNamespace,
+	).Build()
+
+	tests := []struct {
+		name       string
+		jsonServer *JsonServer
+		wantErr    string
+	}{
+		{name: "update within quota", jsonServer: newQuotaTestJsonServer("app-one", 2, `{"a":1}`)},
+		{name: "too many replicas", jsonServer: newQuotaTestJsonServer("app-one", 3, "{}"), wantErr: "exceeded JsonServerQuota quota: maxTotalReplicas 5 (requested 6)"},
+		{name: "too large jsonConfig", jsonServer: newQuotaTestJsonServer("app-one", 1, `{"abc":123}`), wantErr: "exceeded JsonServerQuota quota: maxJsonConfigBytes 10 (requested 13)"},
+		{name: "too many JsonServers", jsonServer: newQuotaTestJsonServer("app-new", 0, ""), wantErr: "exceeded JsonServerQuota quota: maxJsonServers 2 (requested 3)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkQuotas(tt.jsonServer)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_quotaUsageGrows(t *testing.T) {
+	old := newQuotaTestJsonServer("app-one", 2, `{"a":1}`)
+	assert.True(t, quotaUsageGrows(nil, old))
+	assert.False(t, quotaUsageGrows(old, old.DeepCopy()))
+	assert.False(t, quotaUsageGrows(old, newQuotaTestJsonServer("app-one", 1, "{}")))
+	assert.True(t, quotaUsageGrows(old, newQuotaTestJsonServer("app-one", 3, `{"a":1}`)))
+	assert.True(t, quotaUsageGrows(old, newQuotaTestJsonServer("app-one", 2, `{"a":12}`)))
+}
+
+func TestJsonServerQuota_Exceeded(t *testing.T) {
+	maxReplicas := int32(5)
+	quota := &JsonServerQuota{Spec: JsonServerQuotaSpec{MaxTotalReplicas: &maxReplicas}}
+	assert.Empty(t, quota.Exceeded(JsonServerQuotaUsage{TotalReplicas: 8}, JsonServerQuotaUsage{TotalReplicas: 7}), "usage above a lowered quota may decrease")
+	assert.Len(t, quota.Exceeded(JsonServerQuotaUsage{TotalReplicas: 5}, JsonServerQuotaUsage{TotalReplicas: 6}), 1)
+}
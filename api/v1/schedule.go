@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"fmt"
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"strings"
+	"time"
+)
+
+var scheduleParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// Parse returns the cron schedule in the time zone of the schedule.
+func (s ScalingSchedule) Parse() (cron.Schedule, error) {
+	if strings.Contains(s.Schedule, "TZ=") {
+		return nil, fmt.Errorf("time zone must be set by timeZone, not in the schedule")
+	}
+	location := time.UTC
+	if s.TimeZone != "" {
+		var err error
+		if location, err = time.LoadLocation(s.TimeZone); err != nil {
+			return nil, errors.Wrapf(err, "invalid time zone")
+		}
+	}
+	schedule, err := scheduleParser.Parse(s.Schedule)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schedule")
+	}
+	if _, ok := schedule.(cron.ConstantDelaySchedule); ok {
+		// intervals count from an arbitrary time, so the last scheduled scaling cannot be found again after a restart
+		return nil, fmt.Errorf("invalid schedule, @every is not supported")
+	}
+	if spec, ok := schedule.(*cron.SpecSchedule); ok {
+		spec.Location = location
+	}
+	return schedule, nil
+}
+
+func validateSchedules(jsonServer *JsonServer) []string {
+	validationErrors := make([]string, 0)
+	if jsonServer.Spec.Autoscaling != nil {
+		validationErrors = append(validationErrors, "schedules cannot be used with autoscaling, the autoscaler manages replicas")
+	}
+	names := map[string]bool{}
+	for i, schedule := range jsonServer.Spec.Schedules {
+		if schedule.Name == "" {
+			validationErrors = append(validationErrors, fmt.Sprintf("schedules[%d].name is required", i))
+		} else if names[schedule.Name] {
+			validationErrors = append(validationErrors, fmt.Sprintf("schedules[%d].name '%s' is not unique", i, schedule.Name))
+		}
+		names[schedule.Name] = true
+		if _, err := schedule.Parse(); err != nil {
+			validationErrors = append(validationErrors, fmt.Sprintf("schedules[%d] %s", i, err))
+		}
+		for _, replicasErr := range validateReplicas(schedule.Replicas) {
+			validationErrors = append(validationErrors, fmt.Sprintf("schedules[%d] %s", i, replicasErr))
+		}
+	}
+	return validationErrors
+}
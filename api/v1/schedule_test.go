@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestScalingSchedule_Parse(t *testing.T) {
+	from := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	warsaw, err := time.LoadLocation("Europe/Warsaw")
+	assert.NoError(t, err)
+	tests := []struct {
+		name     string
+		schedule ScalingSchedule
+		wantNext time.Time
+		wantErr  bool
+	}{
+		{name: "utc by default", schedule: ScalingSchedule{Schedule: "0 18 * * *"}, wantNext: time.Date(2024, 1, 1, 18, 0, 0, 0, time.UTC)},
+		{name: "time zone", schedule: ScalingSchedule{Schedule: "0 18 * * *", TimeZone: "Europe/Warsaw"}, wantNext: time.Date(2024, 1, 1, 18, 0, 0, 0, warsaw)},
+		{name: "descriptor", schedule: ScalingSchedule{Schedule: "@daily"}, wantNext: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "time zone in schedule", schedule: ScalingSchedule{Schedule: "TZ=Europe/Warsaw 0 18 * * *"}, wantErr: true},
+		{name: "unknown time zone", schedule: ScalingSchedule{Schedule: "0 18 * * *", TimeZone: "Mars/Olympus"}, wantErr: true},
+		{name: "seconds are not supported", schedule: ScalingSchedule{Schedule: "0 0 18 * * *"}, wantErr: true},
+		{name: "every is not supported", schedule: ScalingSchedule{Schedule: "@every 1h"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := tt.schedule.Parse()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, tt.wantNext.Equal(schedule.Next(from)), "next = %s", schedule.Next(from))
+		})
+	}
+}
+
+func Test_validateSchedules(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       JsonServerSpec
+		wantErrors int
+	}{
+		{name: "valid", spec: JsonServerSpec{Schedules: []ScalingSchedule{{Name: "day", Schedule: "0 8 * * 1-5", Replicas: 3}, {Name: "night", Schedule: "0 20 * * 1-5", Replicas: 0}}}},
+		{name: "missing name", spec: JsonServerSpec{Schedules: []ScalingSchedule{{Schedule: "0 8 * * *", Replicas: 1}}}, wantErrors: 1},
+		{name: "duplicated name", spec: JsonServerSpec{Schedules: []ScalingSchedule{{Name: "a", Schedule: "0 8 * * *"}, {Name: "a", Schedule: "0 9 * * *"}}}, wantErrors: 1},
+		{name: "invalid schedule", spec: JsonServerSpec{Schedules: []ScalingSchedule{{Name: "a", Schedule: "every day"}}}, wantErrors: 1},
+		{name: "interval", spec: JsonServerSpec{Schedules: []ScalingSchedule{{Name: "a", Schedule: "@every 30m"}}}, wantErrors: 1},
+		{name: "negative replicas", spec: JsonServerSpec{Schedules: []ScalingSchedule{{Name: "a", Schedule: "@daily", Replicas: -1}}}, wantErrors: 1},
+		{name: "with autoscaling", spec: JsonServerSpec{Autoscaling: &AutoscalingSpec{MaxReplicas: 3}, Schedules: []ScalingSchedule{{Name: "a", Schedule: "@daily"}}}, wantErrors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := validateSchedules(&JsonServer{Spec: tt.spec})
+			assert.Len(t, errs, tt.wantErrors, "%v", errs)
+		})
+	}
+}
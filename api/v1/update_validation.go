@@ -0,0 +1,126 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// immutableField is a field of JsonServer that cannot be changed once JsonServer is created, as children would have to be recreated or orphaned
+type immutableField struct {
+	path   string
+	reason string
+	// applies reports if the field is immutable for old, it is always immutable if nil
+	applies func(old *JsonServer) bool
+	value   func(jsonServer *JsonServer) interface{}
+}
+
+// immutableFields are rejected by ValidateUpdate when changed, the JsonServer has to be recreated instead
+var immutableFields = []immutableField{
+	{
+		path:   "metadata.labels",
+		reason: "they are part of the selector of the Deployment, which cannot be changed",
+		value: func(jsonServer *JsonServer) interface{} {
+			if len(jsonServer.Labels) == 0 {
+				return nil
+			}
+			return jsonServer.Labels
+		},
+	},
+	{
+		path:    "spec.adoptionPolicy",
+		reason:  "children were renamed to status.childName",
+		applies: func(old *JsonServer) bool { return old.Status.ChildName != "" },
+		value:   func(jsonServer *JsonServer) interface{} { return jsonServer.Spec.AdoptionPolicy },
+	},
+}
+
+// validateImmutableFields returns errors for immutable fields changed by the update from old.
+func (r *JsonServer) validateImmutableFields(old *JsonServer) []string {
+	validationErrors := make([]string, 0)
+	for _, field := range immutableFields {
+		if field.applies != nil && !field.applies(old) {
+			continue
+		}
+		if !reflect.DeepEqual(field.value(old), field.value(r)) {
+			validationErrors = append(validationErrors, fmt.Sprintf("%s is immutable as %s, recreate the JsonServer to change it", field.path, field.reason))
+		}
+	}
+	return validationErrors
+}
+
+// updateWarnings describes changes that are allowed but may break clients of json-server.
+func (r *JsonServer) updateWarnings(old *JsonServer) []string {
+	warnings := make([]string, 0)
+	if removed := removedCollections(old, r); len(removed) > 0 {
+		warnings = append(warnings, fmt.Sprintf("collections removed from jsonConfig: %s, their data is lost", strings.Join(removed, ", ")))
+	}
+	if (old.Spec.TLS == nil) != (r.Spec.TLS == nil) {
+		scheme := "http"
+		if r.Spec.TLS != nil {
+			scheme = "https"
+		}
+		warnings = append(warnings, fmt.Sprintf("tls changed, clients have to use %s", scheme))
+	}
+	if old.Spec.Auth == nil && r.Spec.Auth != nil {
+		warnings = append(warnings, "auth enabled, requests without credentials are rejected")
+	}
+	if old.Spec.Templated != r.Spec.Templated {
+		warnings = append(warnings, fmt.Sprintf("templated changed to %t, jsonConfig is interpreted differently", r.Spec.Templated))
+	}
+	if oldClass, newClass := className(old), className(r); oldClass != newClass {
+		warnings = append(warnings, fmt.Sprintf("className changed from %s to %s, pods are recreated with settings of the class", oldClass, newClass))
+	}
+	if oldType, newType := serviceType(old), serviceType(r); oldType != newType {
+		warnings = append(warnings, fmt.Sprintf("service type changed from %s to %s, the address of json-server may change", oldType, newType))
+	}
+	return warnings
+}
+
+// removedCollections returns collections (top-level keys) of rendered jsonConfig of old that are missing in jsonServer.
+func removedCollections(old *JsonServer, jsonServer *JsonServer) []string {
+	oldCollections := renderedCollections(old)
+	newCollections := renderedCollections(jsonServer)
+	if oldCollections == nil || newCollections == nil {
+		// invalid jsonConfig is reported by validate
+		return nil
+	}
+	removed := make([]string, 0)
+	for collection := range oldCollections {
+		if _, ok := newCollections[collection]; !ok {
+			removed = append(removed, collection)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+func renderedCollections(jsonServer *JsonServer) map[string]json.RawMessage {
+	jsonConfig, err := jsonServer.RenderedJsonConfig()
+	if err != nil {
+		return nil
+	}
+	collections := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(jsonConfig), &collections); err != nil {
+		return nil
+	}
+	return collections
+}
+
+// serviceType returns the service type set in JsonServer, classes are not taken into account
+func serviceType(jsonServer *JsonServer) string {
+	if service := jsonServer.Spec.Service; service != nil && service.Type != "" {
+		return string(service.Type)
+	}
+	return "default"
+}
+
+// className returns the class referenced by JsonServer, the default class is not resolved
+func className(jsonServer *JsonServer) string {
+	if jsonServer.Spec.ClassName != nil {
+		return *jsonServer.Spec.ClassName
+	}
+	return "default"
+}
@@ -0,0 +1,112 @@
+package v1
+
+import (
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func newUpdateTestJsonServer(jsonConfig string) *JsonServer {
+	return &JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-update", Namespace: "team-a"},
+		Spec:       JsonServerSpec{JsonConfig: jsonConfig},
+	}
+}
+
+func TestJsonServer_ValidateUpdate(t *testing.T) {
+	old := newUpdateTestJsonServer(`{"people": [], "posts": []}`)
+	tests := []struct {
+		name         string
+		update       func(js *JsonServer)
+		wantErr      string
+		wantWarnings []string
+	}{
+		{name: "no change", update: func(js *JsonServer) {}},
+		{name: "collection added", update: func(js *JsonServer) { js.Spec.JsonConfig = `{"people": [], "posts": [], "tags": []}` }},
+		{
+			name:         "templated changed",
+			update:       func(js *JsonServer) { js.Spec.Templated = true },
+			wantWarnings: []string{"templated changed to true, jsonConfig is interpreted differently"},
+		},
+		{
+			name:    "labels changed",
+			update:  func(js *JsonServer) { js.Labels = map[string]string{"team": "a"} },
+			wantErr: "validation issues: metadata.labels is immutable as they are part of the selector of the Deployment, which cannot be changed, recreate the JsonServer to change it",
+		},
+		{name: "labels emptied", update: func(js *JsonServer) { js.Labels = map[string]string{} }},
+		{name: "adoption policy changed", update: func(js *JsonServer) { js.Spec.AdoptionPolicy = AdoptionPolicyAdopt }},
+		{
+			name:         "class changed",
+			update:       func(js *JsonServer) { className := "large"; js.Spec.ClassName = &className },
+			wantWarnings: []string{"className changed from default to large, pods are recreated with settings of the class"},
+		},
+		{
+			name:         "collections removed",
+			update:       func(js *JsonServer) { js.Spec.JsonConfig = `{"tags": []}` },
+			wantWarnings: []string{"collections removed from jsonConfig: people, posts, their data is lost"},
+		},
+		{
+			name:         "tls enabled",
+			update:       func(js *JsonServer) { js.Spec.TLS = &TLSSpec{SecretName: "tls"} },
+			wantWarnings: []string{"tls changed, clients have to use https"},
+		},
+		{
+			name: "auth enabled",
+			update: func(js *JsonServer) {
+				js.Spec.Auth = &AuthSpec{BasicAuth: []BasicAuthUser{{SecretName: "user"}}}
+			},
+			wantWarnings: []string{"auth enabled, requests without credentials are rejected"},
+		},
+		{
+			name:         "service type changed",
+			update:       func(js *JsonServer) { js.Spec.Service = &ServiceSettings{Type: corev1.ServiceTypeLoadBalancer} },
+			wantWarnings: []string{"service type changed from default to LoadBalancer, the address of json-server may change"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonServer := old.DeepCopy()
+			tt.update(jsonServer)
+			warnings, err := jsonServer.ValidateUpdate(old)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.wantErr)
+			}
+			if tt.wantWarnings == nil {
+				assert.Empty(t, warnings)
+			} else {
+				assert.Equal(t, tt.wantWarnings, []string(warnings))
+			}
+		})
+	}
+}
+
+func TestJsonServer_ValidateUpdate_renamed(t *testing.T) {
+	old := newUpdateTestJsonServer(`{"people": []}`)
+	old.Spec.AdoptionPolicy = AdoptionPolicyRename
+	old.Status.ChildName = "app-update-1a2b3c4d"
+	jsonServer := old.DeepCopy()
+	jsonServer.Spec.AdoptionPolicy = AdoptionPolicyFail
+	_, err := jsonServer.ValidateUpdate(old)
+	assert.EqualError(t, err, "validation issues: spec.adoptionPolicy is immutable as children were renamed to status.childName, recreate the JsonServer to change it")
+}
+
+func TestJsonServer_ValidateUpdate_existing(t *testing.T) {
+	old := &JsonServer{Spec: JsonServerSpec{JsonConfig: "{}"}}
+	old.Name = "legacy-name"
+	jsonServer := old.DeepCopy()
+	_, err := jsonServer.ValidateCreate()
+	assert.EqualError(t, err, "validation issues: resource name must start with 'app-'")
+	_, err = jsonServer.ValidateUpdate(old)
+	assert.NoError(t, err, "naming policy is not checked for existing JsonServers")
+
+	jsonServer.Spec.JsonConfig = "invalid"
+	_, err = jsonServer.ValidateUpdate(old)
+	assert.Error(t, err)
+	now := metav1.Now()
+	jsonServer.DeletionTimestamp = &now
+	_, err = jsonServer.ValidateUpdate(old)
+	assert.NoError(t, err, "finalizers of a deleted JsonServer can be removed")
+}
@@ -2,9 +2,226 @@ package v1
 
 import (
 	"encoding/json"
+	"fmt"
+	"github.com/m-szalik/json-server-operator/internal/jsontemplate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// headerNameRegexp matches HTTP header names (tokens of RFC 7230)
+var headerNameRegexp = regexp.MustCompile("^[!#$%&'*+\\-.^_`|~0-9A-Za-z]+$")
+
 func validateJson(jsonContent string) error {
 	var myJon json.RawMessage
 	return json.Unmarshal([]byte(jsonContent), &myJon)
 }
+
+// validateIntOrPercent accepts nil, a non-negative number or a percentage like "25%"
+func validateIntOrPercent(value *intstr.IntOrString) error {
+	if value == nil {
+		return nil
+	}
+	if value.Type == intstr.Int {
+		if value.IntVal < 0 {
+			return fmt.Errorf("must be greater or equal 0")
+		}
+		return nil
+	}
+	percent, err := strconv.Atoi(strings.TrimSuffix(value.StrVal, "%"))
+	if !strings.HasSuffix(value.StrVal, "%") || err != nil || percent < 0 || percent > 100 {
+		return fmt.Errorf("must be a number or a percentage between 0%% and 100%%")
+	}
+	return nil
+}
+
+func validateAccessPeer(peer AccessPeer) error {
+	hasSelector := peer.NamespaceSelector != nil || peer.PodSelector != nil
+	if peer.CIDR == "" {
+		if !hasSelector {
+			return fmt.Errorf("requires cidr, namespaceSelector or podSelector")
+		}
+		if len(peer.Except) > 0 {
+			return fmt.Errorf("except can be used only with cidr")
+		}
+		for _, selector := range []*metav1.LabelSelector{peer.NamespaceSelector, peer.PodSelector} {
+			if _, err := metav1.LabelSelectorAsSelector(selector); err != nil {
+				return fmt.Errorf("invalid selector - %s", err)
+			}
+		}
+		return nil
+	}
+	if hasSelector {
+		return fmt.Errorf("cidr cannot be combined with namespaceSelector or podSelector")
+	}
+	_, ipNet, err := net.ParseCIDR(peer.CIDR)
+	if err != nil {
+		return fmt.Errorf("invalid cidr - %s", err)
+	}
+	for _, except := range peer.Except {
+		exceptIP, exceptNet, err := net.ParseCIDR(except)
+		if err != nil {
+			return fmt.Errorf("invalid except cidr - %s", err)
+		}
+		exceptSize, _ := exceptNet.Mask.Size()
+		cidrSize, _ := ipNet.Mask.Size()
+		if !ipNet.Contains(exceptIP) || exceptSize < cidrSize {
+			return fmt.Errorf("except %s is not within cidr %s", except, peer.CIDR)
+		}
+	}
+	return nil
+}
+
+func validateAuth(auth *AuthSpec) []string {
+	validationErrors := make([]string, 0)
+	if len(auth.BearerTokens) == 0 && len(auth.BasicAuth) == 0 && auth.JWT == nil {
+		validationErrors = append(validationErrors, "auth requires bearerTokens, basicAuth or jwt")
+	}
+	for i, token := range auth.BearerTokens {
+		if token.SecretKeyRef.Name == "" || token.SecretKeyRef.Key == "" {
+			validationErrors = append(validationErrors, fmt.Sprintf("auth.bearerTokens[%d].secretKeyRef requires name and key", i))
+		}
+		if token.Principal == "" {
+			validationErrors = append(validationErrors, fmt.Sprintf("auth.bearerTokens[%d].principal is required", i))
+		}
+	}
+	for i, user := range auth.BasicAuth {
+		if user.SecretName == "" {
+			validationErrors = append(validationErrors, fmt.Sprintf("auth.basicAuth[%d].secretName is required", i))
+		}
+	}
+	if auth.JWT != nil && (auth.JWT.JWKS.Name == "" || auth.JWT.JWKS.Key == "") {
+		validationErrors = append(validationErrors, "auth.jwt.jwks requires name and key")
+	}
+	return validationErrors
+}
+
+func validateTLS(tls *TLSSpec) []string {
+	validationErrors := make([]string, 0)
+	if (tls.SecretName == "") == (tls.IssuerRef == nil) {
+		validationErrors = append(validationErrors, "tls requires exactly one of secretName and issuerRef")
+	}
+	if tls.IssuerRef != nil && tls.IssuerRef.Name == "" {
+		validationErrors = append(validationErrors, "tls.issuerRef.name is required")
+	}
+	return validationErrors
+}
+
+func validateHTTP(spec *HTTPSpec) []string {
+	validationErrors := make([]string, 0)
+	if cors := spec.CORS; cors != nil {
+		for i, origin := range cors.AllowedOrigins {
+			if origin == "*" {
+				if cors.AllowCredentials {
+					validationErrors = append(validationErrors, "http.cors.allowedOrigins cannot contain * when allowCredentials is set")
+				}
+				continue
+			}
+			if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" {
+				validationErrors = append(validationErrors, fmt.Sprintf("http.cors.allowedOrigins[%d] must be * or an origin like https://example.com", i))
+			}
+		}
+		for _, headers := range [][]string{cors.AllowedHeaders, cors.ExposedHeaders} {
+			for _, name := range headers {
+				if !headerNameRegexp.MatchString(name) {
+					validationErrors = append(validationErrors, fmt.Sprintf("http.cors header '%s' is not a valid header name", name))
+				}
+			}
+		}
+	}
+	for i, route := range spec.Headers {
+		if route.Path != "" {
+			if _, err := path.Match(route.Path, "/"); err != nil || !strings.HasPrefix(route.Path, "/") {
+				validationErrors = append(validationErrors, fmt.Sprintf("http.headers[%d].path must be a valid pattern starting with /", i))
+			}
+		}
+		for name := range route.Headers {
+			if !headerNameRegexp.MatchString(name) {
+				validationErrors = append(validationErrors, fmt.Sprintf("http.headers[%d] header '%s' is not a valid header name", i, name))
+			} else if strings.HasPrefix(strings.ToLower(name), "access-control-") {
+				validationErrors = append(validationErrors, fmt.Sprintf("http.headers[%d] header '%s' must be configured with http.cors", i, name))
+			}
+		}
+	}
+	return validationErrors
+}
+
+// validateIdle checks idle settings against features the activator does not support
+func validateIdle(jsonServer *JsonServer) []string {
+	validationErrors := make([]string, 0)
+	if jsonServer.Spec.Idle.IdleAfter.Duration < time.Minute {
+		validationErrors = append(validationErrors, "idle.idleAfter must be at least 1m")
+	}
+	if jsonServer.Spec.Autoscaling != nil {
+		validationErrors = append(validationErrors, "idle cannot be used with autoscaling, the autoscaler does not scale to zero")
+	}
+	if jsonServer.Spec.TLS != nil {
+		validationErrors = append(validationErrors, "idle cannot be used with tls, the activator holds requests over http")
+	}
+	return validationErrors
+}
+
+// validateAccessRules checks rules against collections (top-level keys) of the rendered jsonConfig
+func validateAccessRules(rules []AccessRule, auth *AuthSpec, jsonConfig string) []string {
+	validationErrors := make([]string, 0)
+	collections := map[string]json.RawMessage{}
+	// invalid jsonConfig is reported separately, then there are no known collections
+	_ = json.Unmarshal([]byte(jsonConfig), &collections)
+	for i, rule := range rules {
+		switch {
+		case (rule.Collection == "") == (rule.Path == ""):
+			validationErrors = append(validationErrors, fmt.Sprintf("accessRules[%d] requires exactly one of collection and path", i))
+		case rule.Collection != "":
+			if _, ok := collections[rule.Collection]; !ok {
+				validationErrors = append(validationErrors, fmt.Sprintf("accessRules[%d].collection '%s' is not present in jsonConfig", i, rule.Collection))
+			}
+		default:
+			if _, err := path.Match(rule.Path, "/"); err != nil || !strings.HasPrefix(rule.Path, "/") {
+				validationErrors = append(validationErrors, fmt.Sprintf("accessRules[%d].path must be a valid pattern starting with /", i))
+			}
+		}
+		if auth == nil && (len(rule.Principals) > 0 || len(rule.Roles) > 0) {
+			validationErrors = append(validationErrors, fmt.Sprintf("accessRules[%d] with principals or roles requires auth", i))
+		}
+	}
+	return validationErrors
+}
+
+// ChildName returns the name of children (Deployment, Service, ConfigMap, ...) and of the app label of pods,
+// the name of the JsonServer unless children were renamed by AdoptionPolicyRename.
+func (r *JsonServer) ChildName() string {
+	if r.Status.ChildName != "" {
+		return r.Status.ChildName
+	}
+	return r.Name
+}
+
+// AccessRestricted reports if spec.access limits clients of json-server with a NetworkPolicy.
+func (r *JsonServer) AccessRestricted() bool {
+	access := r.Spec.Access
+	return access != nil && (len(access.AllowFrom) > 0 || access.DefaultDeny)
+}
+
+// RenderedJsonConfig returns jsonConfig with the template rendered if the resource is templated.
+// Date functions are relative to the creation time of the resource, so the result does not change between reconciliations.
+func (r *JsonServer) RenderedJsonConfig() (string, error) {
+	if !r.Spec.Templated {
+		return r.Spec.JsonConfig, nil
+	}
+	baseTime := r.CreationTimestamp.Time
+	if baseTime.IsZero() {
+		baseTime = time.Now()
+	}
+	return jsontemplate.Render(r.Spec.JsonConfig, jsontemplate.Input{
+		Name:      r.Name,
+		Namespace: r.Namespace,
+		Params:    r.Spec.Parameters,
+		BaseTime:  baseTime,
+	})
+}
@@ -0,0 +1,153 @@
+package v1
+
+import (
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+	"time"
+)
+
+func Test_validateIntOrPercent(t *testing.T) {
+	tests := []struct {
+		value   intstr.IntOrString
+		wantErr bool
+	}{
+		{intstr.FromInt(0), false},
+		{intstr.FromInt(3), false},
+		{intstr.FromInt(-1), true},
+		{intstr.FromString("25%"), false},
+		{intstr.FromString("101%"), true},
+		{intstr.FromString("25"), true},
+		{intstr.FromString("abc%"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value.String(), func(t *testing.T) {
+			err := validateIntOrPercent(&tt.value)
+			assert.Equal(t, tt.wantErr, err != nil, "validateIntOrPercent(%s) = %v", tt.value.String(), err)
+		})
+	}
+}
+
+func TestJsonServer_RenderedJsonConfig(t *testing.T) {
+	jsonServer := &JsonServer{Spec: JsonServerSpec{JsonConfig: `{"a":"{{ .Params.x }}"}`}}
+	rendered, err := jsonServer.RenderedJsonConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, jsonServer.Spec.JsonConfig, rendered, "not templated config is returned as is")
+
+	jsonServer.Spec.Templated = true
+	jsonServer.Spec.Parameters = map[string]string{"x": "y"}
+	rendered, err = jsonServer.RenderedJsonConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":"y"}`, rendered)
+}
+
+func Test_validateAccessPeer(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    AccessPeer
+		wantErr bool
+	}{
+		{name: "empty", peer: AccessPeer{}, wantErr: true},
+		{name: "namespace selector", peer: AccessPeer{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}}},
+		{name: "invalid selector", peer: AccessPeer{PodSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "a", Operator: "Bad"}}}}, wantErr: true},
+		{name: "cidr", peer: AccessPeer{CIDR: "10.0.0.0/16", Except: []string{"10.0.1.0/24"}}},
+		{name: "invalid cidr", peer: AccessPeer{CIDR: "10.0.0.0"}, wantErr: true},
+		{name: "except outside of cidr", peer: AccessPeer{CIDR: "10.0.0.0/16", Except: []string{"10.1.0.0/24"}}, wantErr: true},
+		{name: "cidr with selector", peer: AccessPeer{CIDR: "10.0.0.0/16", PodSelector: &metav1.LabelSelector{}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAccessPeer(tt.peer)
+			assert.Equal(t, tt.wantErr, err != nil, "validateAccessPeer() = %v", err)
+		})
+	}
+}
+
+func Test_validateAccessRules(t *testing.T) {
+	jsonConfig := `{"posts": [], "profile": {}}`
+	tests := []struct {
+		name       string
+		rule       AccessRule
+		auth       *AuthSpec
+		wantErrors int
+	}{
+		{name: "collection", rule: AccessRule{Collection: "posts", Methods: []HTTPMethod{"GET"}}},
+		{name: "unknown collection", rule: AccessRule{Collection: "comments"}, wantErrors: 1},
+		{name: "path", rule: AccessRule{Path: "/posts/*"}},
+		{name: "invalid path", rule: AccessRule{Path: "/posts/["}, wantErrors: 1},
+		{name: "relative path", rule: AccessRule{Path: "posts"}, wantErrors: 1},
+		{name: "collection and path", rule: AccessRule{Collection: "posts", Path: "/posts"}, wantErrors: 1},
+		{name: "roles without auth", rule: AccessRule{Collection: "profile", Roles: []string{"writer"}}, wantErrors: 1},
+		{name: "roles with auth", rule: AccessRule{Collection: "profile", Roles: []string{"writer"}}, auth: &AuthSpec{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validationErrors := validateAccessRules([]AccessRule{tt.rule}, tt.auth, jsonConfig)
+			assert.Len(t, validationErrors, tt.wantErrors, "validateAccessRules() = %v", validationErrors)
+		})
+	}
+}
+
+func Test_validateTLS(t *testing.T) {
+	tests := []struct {
+		name       string
+		tls        TLSSpec
+		wantErrors int
+	}{
+		{name: "empty", tls: TLSSpec{}, wantErrors: 1},
+		{name: "secret", tls: TLSSpec{SecretName: "cert"}},
+		{name: "issuer", tls: TLSSpec{IssuerRef: &IssuerReference{Name: "ca", Kind: "ClusterIssuer"}}},
+		{name: "issuer without name", tls: TLSSpec{IssuerRef: &IssuerReference{}}, wantErrors: 1},
+		{name: "secret and issuer", tls: TLSSpec{SecretName: "cert", IssuerRef: &IssuerReference{Name: "ca"}}, wantErrors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, validateTLS(&tt.tls), tt.wantErrors)
+		})
+	}
+}
+
+func Test_validateHTTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       HTTPSpec
+		wantErrors int
+	}{
+		{name: "cors", spec: HTTPSpec{CORS: &CORSSpec{AllowedOrigins: []string{"https://app.example.com", "http://localhost:8080"}, AllowedHeaders: []string{"X-Correlation-Id"}}}},
+		{name: "any origin", spec: HTTPSpec{CORS: &CORSSpec{AllowedOrigins: []string{"*"}}}},
+		{name: "any origin with credentials", spec: HTTPSpec{CORS: &CORSSpec{AllowedOrigins: []string{"*"}, AllowCredentials: true}}, wantErrors: 1},
+		{name: "origin with path", spec: HTTPSpec{CORS: &CORSSpec{AllowedOrigins: []string{"https://app.example.com/app"}}}, wantErrors: 1},
+		{name: "invalid header", spec: HTTPSpec{CORS: &CORSSpec{AllowedOrigins: []string{"*"}, ExposedHeaders: []string{"X Id"}}}, wantErrors: 1},
+		{name: "route headers", spec: HTTPSpec{Headers: []RouteHeaders{{Path: "/posts/*", Headers: map[string]string{"Cache-Control": "no-store"}}}}},
+		{name: "cors route header", spec: HTTPSpec{Headers: []RouteHeaders{{Headers: map[string]string{"Access-Control-Allow-Origin": "*"}}}}, wantErrors: 1},
+		{name: "invalid route path", spec: HTTPSpec{Headers: []RouteHeaders{{Path: "posts", Headers: map[string]string{"X-Mock": "1"}}}}, wantErrors: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			validationErrors := validateHTTP(&tt.spec)
+			assert.Len(t, validationErrors, tt.wantErrors, "validateHTTP() = %v", validationErrors)
+		})
+	}
+}
+
+func Test_validateIdle(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       JsonServerSpec
+		wantErrors int
+	}{
+		{name: "valid", spec: JsonServerSpec{Idle: &IdleSpec{IdleAfter: metav1.Duration{Duration: 30 * time.Minute}}}},
+		{name: "too short", spec: JsonServerSpec{Idle: &IdleSpec{IdleAfter: metav1.Duration{Duration: 10 * time.Second}}}, wantErrors: 1},
+		{name: "with autoscaling and tls", spec: JsonServerSpec{
+			Idle:        &IdleSpec{IdleAfter: metav1.Duration{Duration: time.Hour}},
+			Autoscaling: &AutoscalingSpec{MaxReplicas: 3},
+			TLS:         &TLSSpec{SecretName: "cert"},
+		}, wantErrors: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, validateIdle(&JsonServer{Spec: tt.spec}), tt.wantErrors)
+		})
+	}
+}
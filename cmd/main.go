@@ -17,22 +17,32 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
+	"time"
+	// time zones of scaling schedules are available in distroless images
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/m-szalik/json-server-operator/internal/activator"
 	"github.com/m-szalik/json-server-operator/internal/controller"
+	"github.com/m-szalik/json-server-operator/internal/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -52,11 +62,42 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var sidecarImage string
+	var maxReplicas int
+	var watchNamespaces string
+	var watchLabelSelector string
+	var activatorAddr string
+	var activatorTimeout time.Duration
+	var tracingOptions tracing.Options
+	namingPolicy := examplecomv1.DefaultNamingPolicy
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&sidecarImage, "sidecar-image", os.Getenv("SIDECAR_IMAGE"),
+		"Image of the sidecar injected into json-server pods (the operator image). Defaults to SIDECAR_IMAGE environment variable.")
+	flag.StringVar(&namingPolicy.Prefix, "name-prefix", namingPolicy.Prefix, "Prefix required in names of JsonServers.")
+	flag.StringVar(&namingPolicy.Suffix, "name-suffix", namingPolicy.Suffix, "Suffix required in names of JsonServers.")
+	flag.StringVar(&namingPolicy.Pattern, "name-pattern", namingPolicy.Pattern, "Regular expression names of JsonServers must match.")
+	flag.IntVar(&namingPolicy.MaxLength, "name-max-length", namingPolicy.MaxLength, "Maximum length of names of JsonServers, 0 means no limit.")
+	flag.IntVar(&maxReplicas, "max-replicas", 0, "Maximum number of replicas of a JsonServer, 0 means no limit.")
+	flag.StringVar(&watchNamespaces, "watch-namespaces", "",
+		"Comma separated list of namespaces watched by the operator, all namespaces if empty.")
+	flag.StringVar(&watchLabelSelector, "watch-label-selector", "",
+		"Label selector of JsonServers reconciled by the operator, all JsonServers if empty.")
+	flag.StringVar(&activatorAddr, "activator-bind-address", ":8070",
+		"The address the activator of idle JsonServers binds to, 0 disables scaling JsonServers to zero.")
+	flag.StringVar(&controller.ActivatorService, "activator-service", "json-server-operator-activator-service",
+		"Name of the Service of the activator in the namespace of the operator.")
+	flag.DurationVar(&activatorTimeout, "activator-timeout", 2*time.Minute,
+		"Maximum time the activator holds a request until a pod of the JsonServer is ready.")
+	flag.BoolVar(&controller.DryRun, "dry-run", false,
+		"Plan changes of JsonServers in their status and events without executing them, overridden by the "+examplecomv1.DryRunAnnotation+" annotation.")
+	flag.StringVar(&tracingOptions.Endpoint, "otlp-endpoint", "",
+		"host:port of an OTLP/HTTP collector receiving traces of reconciliation, OTEL_EXPORTER_OTLP_ENDPOINT by default. Tracing is disabled if both are empty.")
+	flag.BoolVar(&tracingOptions.Insecure, "otlp-insecure", false, "Send traces to the OTLP collector over plain HTTP.")
+	flag.Float64Var(&tracingOptions.SampleRatio, "trace-sample-ratio", 1, "Ratio of reconciliations traced, from 0 to 1.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -64,9 +105,51 @@ func main() {
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	if err := namingPolicy.Check(); err != nil {
+		setupLog.Error(err, "invalid naming policy")
+		os.Exit(1)
+	}
+	examplecomv1.DefaultNamingPolicy = namingPolicy
+	examplecomv1.MaxReplicas = int32(maxReplicas)
+	if tracingOptions.Enabled() {
+		shutdownTracing, err := tracing.Setup(context.Background(), tracingOptions)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing")
+			os.Exit(1)
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = shutdownTracing(ctx)
+		}()
+		setupLog.Info("tracing enabled", "endpoint", tracingOptions.Endpoint, "sampleRatio", tracingOptions.SampleRatio)
+	}
+
+	cacheOptions := cache.Options{}
+	if watchNamespaces != "" {
+		for _, ns := range strings.Split(watchNamespaces, ",") {
+			if ns = strings.TrimSpace(ns); ns != "" {
+				cacheOptions.Namespaces = append(cacheOptions.Namespaces, ns)
+			}
+		}
+		examplecomv1.WatchedNamespaces = cacheOptions.Namespaces
+		setupLog.Info("watching namespaces", "namespaces", cacheOptions.Namespaces)
+	}
+	if watchLabelSelector != "" {
+		selector, err := labels.Parse(watchLabelSelector)
+		if err != nil {
+			setupLog.Error(err, "invalid watch label selector")
+			os.Exit(1)
+		}
+		// children of JsonServers are not labeled, only JsonServers are filtered
+		cacheOptions.ByObject = map[client.Object]cache.ByObject{&examplecomv1.JsonServer{}: {Label: selector}}
+		examplecomv1.WatchedSelector = selector
+		setupLog.Info("watching JsonServers", "selector", selector.String())
+	}
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
+		Cache:                  cacheOptions,
 		MetricsBindAddress:     metricsAddr,
 		Port:                   9443,
 		HealthProbeBindAddress: probeAddr,
@@ -90,13 +173,38 @@ func main() {
 	}
 
 	if err = (&controller.JsonServerReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("JsonServer"),
+		Client:            mgr.GetClient(),
+		APIReader:         mgr.GetAPIReader(),
+		Scheme:            mgr.GetScheme(),
+		Recorder:          mgr.GetEventRecorderFor("JsonServer"),
+		SidecarImage:      sidecarImage,
+		OperatorNamespace: os.Getenv("POD_NAMESPACE"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "JsonServer")
 		os.Exit(1)
 	}
+	if err = (&controller.JsonServerQuotaReconciler{
+		Client:    mgr.GetClient(),
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "JsonServerQuota")
+		os.Exit(1)
+	}
+	if activatorAddr != "0" {
+		if err = mgr.Add(&activator.Activator{
+			Client:     mgr.GetClient(),
+			Reader:     mgr.GetAPIReader(),
+			Addr:       activatorAddr,
+			Timeout:    activatorTimeout,
+			Namespaces: examplecomv1.WatchedNamespaces,
+		}); err != nil {
+			setupLog.Error(err, "unable to add activator")
+			os.Exit(1)
+		}
+	} else {
+		controller.ActivatorService = ""
+	}
 	if err = (&examplecomv1.JsonServer{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "JsonServer")
 		os.Exit(1)
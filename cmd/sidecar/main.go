@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/m-szalik/json-server-operator/internal/sidecar"
+)
+
+// sidecar is a proxy injected by the operator into json-server pods, it authenticates and authorizes requests before they reach json-server and terminates TLS.
+func main() {
+	var configFile string
+	flag.StringVar(&configFile, "config", "/config/sidecar.json", "Sidecar configuration file generated by the operator.")
+	flag.Parse()
+
+	config, err := sidecar.LoadConfig(configFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	server, err := sidecar.NewServer(config)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	servers := []*http.Server{
+		{Addr: config.ListenAddress, Handler: server.Handler(), ReadHeaderTimeout: 10 * time.Second, TLSConfig: server.TLSConfig()},
+		{Addr: config.AdminAddress, Handler: server.AdminHandler(), ReadHeaderTimeout: 10 * time.Second},
+	}
+	if handler := server.MetricsHandler(); handler != nil {
+		servers = append(servers, &http.Server{Addr: config.Metrics.Address, Handler: handler, ReadHeaderTimeout: 10 * time.Second})
+	}
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		go func(srv *http.Server) {
+			if srv.TLSConfig != nil {
+				// the certificate is provided by TLSConfig.GetCertificate
+				errs <- srv.ListenAndServeTLS("", "")
+			} else {
+				errs <- srv.ListenAndServe()
+			}
+		}(srv)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err = <-errs:
+		fmt.Fprintln(os.Stderr, err)
+	case <-signals:
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	for _, srv := range servers {
+		_ = srv.Shutdown(ctx)
+	}
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,114 @@
+package activator
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"net"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// podIPField selects pods by their IP, it is supported by the API server
+const podIPField = "status.podIP"
+
+// callerAllowed reports if the client at remoteAddr may call jsonServer. The NetworkPolicy of spec.access allows the activator
+// to connect to json-server, so the activator checks callers by the same rules before it forwards requests.
+func (a *Activator) callerAllowed(ctx context.Context, jsonServer *examplecomv1.JsonServer, remoteAddr string) (bool, error) {
+	if !jsonServer.AccessRestricted() {
+		return true, nil
+	}
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false, nil
+	}
+	access := jsonServer.Spec.Access
+	for _, peer := range access.AllowFrom {
+		if peer.CIDR != "" && cidrContains(peer, ip) {
+			return true, nil
+		}
+	}
+	pod, err := a.podByIP(ctx, ip.String())
+	if err != nil || pod == nil {
+		return false, err
+	}
+	if !access.DefaultDeny && pod.Namespace == jsonServer.Namespace {
+		return true, nil
+	}
+	for _, peer := range access.AllowFrom {
+		if peer.CIDR != "" {
+			continue
+		}
+		matches, err := a.peerMatches(ctx, peer, jsonServer.Namespace, pod)
+		if err != nil || matches {
+			return matches, err
+		}
+	}
+	return false, nil
+}
+
+func cidrContains(peer examplecomv1.AccessPeer, ip net.IP) bool {
+	_, ipNet, err := net.ParseCIDR(peer.CIDR)
+	if err != nil || !ipNet.Contains(ip) {
+		return false
+	}
+	for _, except := range peer.Except {
+		if _, exceptNet, err := net.ParseCIDR(except); err == nil && exceptNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// podByIP returns the pod with the IP in namespaces of the activator, nil if there is none.
+// Pods in the host network share the IP of their node, they are not matched by selectors of NetworkPolicies either.
+func (a *Activator) podByIP(ctx context.Context, ip string) (*corev1.Pod, error) {
+	for _, ns := range a.namespaces() {
+		pods := &corev1.PodList{}
+		if err := a.Reader.List(ctx, pods, client.InNamespace(ns), client.MatchingFields{podIPField: ip}); err != nil {
+			return nil, errors.Wrapf(err, "cannot find pod with IP %s", ip)
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Status.PodIP == ip && !pod.Spec.HostNetwork && pod.Status.Phase == corev1.PodRunning {
+				return pod, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// peerMatches reports if pod matches selectors of peer, a peer without namespaceSelector matches pods in the namespace of JsonServer.
+func (a *Activator) peerMatches(ctx context.Context, peer examplecomv1.AccessPeer, namespace string, pod *corev1.Pod) (bool, error) {
+	if peer.NamespaceSelector == nil {
+		if pod.Namespace != namespace {
+			return false, nil
+		}
+	} else {
+		selector, err := metav1.LabelSelectorAsSelector(peer.NamespaceSelector)
+		if err != nil {
+			return false, err
+		}
+		ns := &corev1.Namespace{}
+		if err := a.Reader.Get(ctx, client.ObjectKey{Name: pod.Namespace}, ns); err != nil {
+			return false, errors.Wrapf(err, "cannot get namespace %s", pod.Namespace)
+		}
+		if !selector.Matches(labels.Set(ns.Labels)) {
+			return false, nil
+		}
+	}
+	if peer.PodSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(peer.PodSelector)
+	if err != nil {
+		return false, err
+	}
+	return selector.Matches(labels.Set(pod.Labels)), nil
+}
@@ -0,0 +1,215 @@
+package activator
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPort  = 3000
+	pollInterval = 500 * time.Millisecond
+)
+
+var activatorlog = logf.Log.WithName("activator")
+
+// Activator receives requests of JsonServers scaled to zero, it scales them up and forwards requests once a pod is ready.
+// The JsonServer is found by the Host header (name, name.namespace, name.namespace.svc...) or by the cluster IP of its Service.
+type Activator struct {
+	// Client scales JsonServers through the scale subresource
+	Client client.Client
+	// Reader reads JsonServers, Services and pods without caching them
+	Reader client.Reader
+	// Addr is the listen address
+	Addr string
+	// Timeout of waiting for a ready pod
+	Timeout time.Duration
+	// Namespaces with JsonServers, all namespaces if empty
+	Namespaces []string
+}
+
+// Start serves requests until ctx is done, it implements manager.Runnable.
+func (a *Activator) Start(ctx context.Context) error {
+	server := &http.Server{Addr: a.Addr, Handler: a, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+	activatorlog.Info("starting activator", "address", a.Addr)
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection is false, every replica of the operator serves requests.
+func (a *Activator) NeedLeaderElection() bool {
+	return false
+}
+
+func (a *Activator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), a.Timeout)
+	defer cancel()
+	jsonServer, err := a.resolve(ctx, r.Host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	allowed, err := a.callerAllowed(ctx, jsonServer, r.RemoteAddr)
+	if err != nil {
+		activatorlog.Error(err, "cannot check caller", "jsonServer", client.ObjectKeyFromObject(jsonServer), "remoteAddr", r.RemoteAddr)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if !allowed {
+		http.Error(w, "caller is not allowed by spec.access", http.StatusForbidden)
+		return
+	}
+	if err := a.activate(ctx, jsonServer); err != nil {
+		activatorlog.Error(err, "cannot scale up", "jsonServer", client.ObjectKeyFromObject(jsonServer))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	target, err := a.waitForPod(ctx, jsonServer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	httputil.NewSingleHostReverseProxy(target).ServeHTTP(w, r)
+}
+
+// resolve finds the idle JsonServer requested by host.
+func (a *Activator) resolve(ctx context.Context, host string) (*examplecomv1.JsonServer, error) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	candidates, err := a.idleJsonServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]*examplecomv1.JsonServer, 0)
+	if ip := net.ParseIP(host); ip != nil {
+		for _, jsonServer := range candidates {
+			service := &corev1.Service{}
+			if err := a.Reader.Get(ctx, client.ObjectKey{Namespace: jsonServer.Namespace, Name: jsonServer.ChildName()}, service); err == nil && service.Spec.ClusterIP == ip.String() {
+				matches = append(matches, jsonServer)
+			}
+		}
+	} else {
+		parts := strings.Split(host, ".")
+		for _, jsonServer := range candidates {
+			if jsonServer.ChildName() == parts[0] && (len(parts) == 1 || jsonServer.Namespace == parts[1]) {
+				matches = append(matches, jsonServer)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no idle JsonServer for host %s", host)
+	case 1:
+		return matches[0], nil
+	default:
+		return nil, fmt.Errorf("host %s matches JsonServers in %d namespaces, use <name>.<namespace>", host, len(matches))
+	}
+}
+
+// namespaces returns namespaces to list objects in, the empty namespace lists all.
+func (a *Activator) namespaces() []string {
+	if len(a.Namespaces) == 0 {
+		return []string{""}
+	}
+	return a.Namespaces
+}
+
+// idleJsonServers returns JsonServers the operator routes to the activator, others are never woken up by it.
+func (a *Activator) idleJsonServers(ctx context.Context) ([]*examplecomv1.JsonServer, error) {
+	idle := make([]*examplecomv1.JsonServer, 0)
+	for _, ns := range a.namespaces() {
+		list := &examplecomv1.JsonServerList{}
+		if err := a.Reader.List(ctx, list, client.InNamespace(ns)); err != nil {
+			return nil, errors.Wrapf(err, "cannot list JsonServers")
+		}
+		for i := range list.Items {
+			if list.Items[i].Spec.Idle != nil && list.Items[i].Status.Idle {
+				idle = append(idle, &list.Items[i])
+			}
+		}
+	}
+	return idle, nil
+}
+
+// activate scales jsonServer up to replicas it had before it was scaled to zero, 1 by default.
+func (a *Activator) activate(ctx context.Context, jsonServer *examplecomv1.JsonServer) error {
+	if jsonServer.Spec.Replicas == nil || *jsonServer.Spec.Replicas > 0 {
+		return nil
+	}
+	replicas := int32(1)
+	if value, err := strconv.Atoi(jsonServer.Annotations[examplecomv1.IdleReplicasAnnotation]); err == nil && value > 0 {
+		replicas = int32(value)
+	}
+	activatorlog.Info("scaling up idle JsonServer", "jsonServer", client.ObjectKeyFromObject(jsonServer), "replicas", replicas)
+	scale := &autoscalingv1.Scale{Spec: autoscalingv1.ScaleSpec{Replicas: replicas}}
+	if err := a.Client.SubResource("scale").Update(ctx, jsonServer, client.WithSubResourceBody(scale)); err != nil {
+		return errors.Wrapf(err, "cannot scale %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	return nil
+}
+
+// waitForPod returns the address of a ready pod of jsonServer.
+func (a *Activator) waitForPod(ctx context.Context, jsonServer *examplecomv1.JsonServer) (*url.URL, error) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		pods := &corev1.PodList{}
+		if err := a.Reader.List(ctx, pods, client.InNamespace(jsonServer.Namespace), client.MatchingLabels{"app": jsonServer.ChildName()}); err != nil {
+			return nil, errors.Wrapf(err, "cannot list pods of %s", client.ObjectKeyFromObject(jsonServer))
+		}
+		for i := range pods.Items {
+			if target := podTarget(&pods.Items[i]); target != nil {
+				return target, nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("no pod of %s is ready", client.ObjectKeyFromObject(jsonServer))
+		case <-ticker.C:
+		}
+	}
+}
+
+// podTarget returns the http address of a ready pod, nil if the pod is not ready.
+func podTarget(pod *corev1.Pod) *url.URL {
+	if pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+		return nil
+	}
+	ready := false
+	for _, condition := range pod.Status.Conditions {
+		ready = ready || (condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue)
+	}
+	if !ready {
+		return nil
+	}
+	port := int32(defaultPort)
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.Name == "http" {
+				port = p.ContainerPort
+			}
+		}
+	}
+	return &url.URL{Scheme: "http", Host: net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port)))}
+}
@@ -0,0 +1,191 @@
+package activator
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, clientgoscheme.AddToScheme(scheme))
+	assert.NoError(t, examplecomv1.AddToScheme(scheme))
+	return scheme
+}
+
+func newIdleJsonServer(name string, namespace string, replicas int32) *examplecomv1.JsonServer {
+	return &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Spec: examplecomv1.JsonServerSpec{
+			Replicas: &replicas,
+			Idle:     &examplecomv1.IdleSpec{IdleAfter: metav1.Duration{Duration: time.Hour}},
+		},
+		Status: examplecomv1.JsonServerStatus{Idle: true},
+	}
+}
+
+func TestActivator_resolve(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(
+		newIdleJsonServer("app-one", "team-a", 0),
+		newIdleJsonServer("app-two", "team-a", 0),
+		newIdleJsonServer("app-two", "team-b", 0),
+		&examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-not-idle", Namespace: "team-a"}},
+		&examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-running", Namespace: "team-a"}, Spec: newIdleJsonServer("", "", 1).Spec},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "app-one", Namespace: "team-a"}, Spec: corev1.ServiceSpec{ClusterIP: "10.0.0.12"}},
+	).Build()
+	a := &Activator{Client: c, Reader: c}
+	tests := []struct {
+		host          string
+		wantNamespace string
+		wantName      string
+		wantErr       string
+	}{
+		{host: "app-one:3000", wantNamespace: "team-a", wantName: "app-one"},
+		{host: "app-two.team-b.svc.cluster.local", wantNamespace: "team-b", wantName: "app-two"},
+		{host: "10.0.0.12:3000", wantNamespace: "team-a", wantName: "app-one"},
+		{host: "app-two", wantErr: "host app-two matches JsonServers in 2 namespaces, use <name>.<namespace>"},
+		{host: "app-not-idle", wantErr: "no idle JsonServer for host app-not-idle"},
+		{host: "app-running", wantErr: "no idle JsonServer for host app-running"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			jsonServer, err := a.resolve(context.TODO(), tt.host)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, client.ObjectKey{Namespace: tt.wantNamespace, Name: tt.wantName}, client.ObjectKeyFromObject(jsonServer))
+		})
+	}
+}
+
+func TestActivator_activate(t *testing.T) {
+	scaled := make(map[string]int32)
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithInterceptorFuncs(interceptor.Funcs{
+		SubResourceUpdate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+			options := &client.SubResourceUpdateOptions{}
+			options.ApplyOptions(opts)
+			scaled[obj.GetName()] = options.SubResourceBody.(*autoscalingv1.Scale).Spec.Replicas
+			return nil
+		},
+	}).Build()
+	a := &Activator{Client: c, Reader: c}
+
+	withAnnotation := newIdleJsonServer("app-one", "team-a", 0)
+	withAnnotation.Annotations = map[string]string{examplecomv1.IdleReplicasAnnotation: "3"}
+	assert.NoError(t, a.activate(context.TODO(), withAnnotation))
+	assert.NoError(t, a.activate(context.TODO(), newIdleJsonServer("app-two", "team-a", 0)))
+	assert.NoError(t, a.activate(context.TODO(), newIdleJsonServer("app-running", "team-a", 2)))
+	assert.Equal(t, map[string]int32{"app-one": 3, "app-two": 1}, scaled)
+}
+
+func TestActivator_ServeHTTP(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer upstream.Close()
+	upstreamURL, _ := url.Parse(upstream.URL)
+	upstreamPort, _ := strconv.Atoi(upstreamURL.Port())
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-one-abc", Namespace: "team-a", Labels: map[string]string{"app": "app-one"}},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name:  "json-server",
+			Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: int32(upstreamPort)}},
+		}}},
+		Status: corev1.PodStatus{
+			PodIP:      upstreamURL.Hostname(),
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(newIdleJsonServer("app-one", "team-a", 1), pod).Build()
+	a := &Activator{Client: c, Reader: c, Timeout: time.Second}
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://app-one.team-a:3000/people", nil)
+	a.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `[]`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "http://app-unknown:3000/people", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	restricted := newIdleJsonServer("app-one", "team-a", 1)
+	restricted.Spec.Access = &examplecomv1.AccessSpec{AllowFrom: []examplecomv1.AccessPeer{{CIDR: "10.0.0.0/8"}}}
+	c = fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(restricted, pod).WithIndex(&corev1.Pod{}, podIPField, podIPIndex).Build()
+	a = &Activator{Client: c, Reader: c, Timeout: time.Second}
+	rec = httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest("GET", "http://app-one.team-a:3000/people", nil))
+	assert.Equal(t, http.StatusForbidden, rec.Code, "the caller is not allowed by spec.access")
+}
+
+func podIPIndex(o client.Object) []string {
+	return []string{o.(*corev1.Pod).Status.PodIP}
+}
+
+func TestActivator_callerAllowed(t *testing.T) {
+	newPod := func(name string, namespace string, ip string, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels},
+			Status:     corev1.PodStatus{PodIP: ip, Phase: corev1.PodRunning},
+		}
+	}
+	hostNetworkPod := newPod("node-agent", "team-a", "10.1.0.1", nil)
+	hostNetworkPod.Spec.HostNetwork = true
+	c := fake.NewClientBuilder().WithScheme(newTestScheme(t)).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "frontend", Labels: map[string]string{"team": "frontend"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "other"}},
+		newPod("neighbour", "team-a", "10.2.0.1", nil),
+		newPod("web", "frontend", "10.2.0.2", map[string]string{"app": "web"}),
+		newPod("batch", "frontend", "10.2.0.3", map[string]string{"app": "batch"}),
+		newPod("stranger", "other", "10.2.0.4", map[string]string{"app": "web"}),
+		hostNetworkPod,
+	).WithIndex(&corev1.Pod{}, podIPField, podIPIndex).Build()
+	a := &Activator{Client: c, Reader: c}
+	frontend := examplecomv1.AccessPeer{
+		NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "frontend"}},
+		PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+	}
+	tests := []struct {
+		name       string
+		access     *examplecomv1.AccessSpec
+		remoteAddr string
+		want       bool
+	}{
+		{name: "no access restrictions", remoteAddr: "10.2.0.4:40000", want: true},
+		{name: "own namespace", access: &examplecomv1.AccessSpec{AllowFrom: []examplecomv1.AccessPeer{frontend}}, remoteAddr: "10.2.0.1:40000", want: true},
+		{name: "own namespace denied", access: &examplecomv1.AccessSpec{DefaultDeny: true, AllowFrom: []examplecomv1.AccessPeer{frontend}}, remoteAddr: "10.2.0.1:40000"},
+		{name: "selected pod", access: &examplecomv1.AccessSpec{DefaultDeny: true, AllowFrom: []examplecomv1.AccessPeer{frontend}}, remoteAddr: "10.2.0.2:40000", want: true},
+		{name: "other pod of selected namespace", access: &examplecomv1.AccessSpec{DefaultDeny: true, AllowFrom: []examplecomv1.AccessPeer{frontend}}, remoteAddr: "10.2.0.3:40000"},
+		{name: "pod of other namespace", access: &examplecomv1.AccessSpec{DefaultDeny: true, AllowFrom: []examplecomv1.AccessPeer{frontend}}, remoteAddr: "10.2.0.4:40000"},
+		{name: "host network pod", access: &examplecomv1.AccessSpec{AllowFrom: []examplecomv1.AccessPeer{frontend}}, remoteAddr: "10.1.0.1:40000"},
+		{name: "cidr", access: &examplecomv1.AccessSpec{DefaultDeny: true, AllowFrom: []examplecomv1.AccessPeer{{CIDR: "192.168.0.0/16"}}}, remoteAddr: "192.168.1.1:40000", want: true},
+		{name: "cidr except", access: &examplecomv1.AccessSpec{DefaultDeny: true, AllowFrom: []examplecomv1.AccessPeer{{CIDR: "192.168.0.0/16", Except: []string{"192.168.1.0/24"}}}}, remoteAddr: "192.168.1.1:40000"},
+		{name: "unknown address", access: &examplecomv1.AccessSpec{AllowFrom: []examplecomv1.AccessPeer{frontend}}, remoteAddr: "172.16.0.1:40000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonServer := newIdleJsonServer("app-one", "team-a", 0)
+			jsonServer.Spec.Access = tt.access
+			allowed, err := a.callerAllowed(context.TODO(), jsonServer, tt.remoteAddr)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, allowed)
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	"hash/fnv"
+	v1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+)
+
+// childKey returns the key of children of jsonServer, they are named alike.
+func childKey(jsonServer *examplecomv1.JsonServer) client.ObjectKey {
+	return client.ObjectKey{Namespace: jsonServer.Namespace, Name: jsonServer.ChildName()}
+}
+
+func adoptionPolicy(jsonServer *examplecomv1.JsonServer) examplecomv1.AdoptionPolicy {
+	if jsonServer.Spec.AdoptionPolicy == "" {
+		return examplecomv1.AdoptionPolicyFail
+	}
+	return jsonServer.Spec.AdoptionPolicy
+}
+
+// renamedChildName returns the name of children used when the name of the JsonServer is taken,
+// the suffix is derived from the UID so the same name is chosen if the status is not stored.
+func renamedChildName(jsonServer *examplecomv1.JsonServer) string {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(jsonServer.UID))
+	// names of Services are limited to 63 characters
+	name := jsonServer.Name
+	if len(name) > 54 {
+		name = strings.TrimRight(name[:54], "-")
+	}
+	return fmt.Sprintf("%s-%08x", name, hash.Sum32())
+}
+
+// renameChildren chooses another name of children if the name of the JsonServer is taken by a resource it does not own.
+// Children are renamed only before they are created, later conflicts are reported. With planOnly the rename is a planned action.
+func (r *JsonServerReconciler) renameChildren(ctx context.Context, jsonServer *examplecomv1.JsonServer, planOnly bool) error {
+	if adoptionPolicy(jsonServer) != examplecomv1.AdoptionPolicyRename || jsonServer.Status.ChildName != "" {
+		return nil
+	}
+	taken := make([]string, 0)
+	for _, child := range r.childResources() {
+		if (child.available != nil && !child.available()) || (child.required != nil && !child.required(jsonServer)) {
+			continue
+		}
+		current := child.create(jsonServer)
+		err := r.Get(ctx, childKey(jsonServer), current)
+		if k8errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return errors.Wrapf(err, "cannot get %s %s", objectType(current), childKey(jsonServer))
+		}
+		if metav1.IsControlledBy(current, jsonServer) {
+			return nil
+		}
+		taken = append(taken, objectType(current))
+	}
+	if len(taken) > 0 {
+		jsonServer.Status.ChildName = renamedChildName(jsonServer)
+		message := fmt.Sprintf("children are named %s, %s %s exist and are not owned by the JsonServer",
+			jsonServer.Status.ChildName, strings.Join(taken, ", "), jsonServer.Name)
+		if planOnly {
+			planAction(jsonServer, "Rename-Children", message)
+		} else {
+			r.Recorder.Event(jsonServer, "Normal", "Renamed", message)
+		}
+	}
+	return nil
+}
+
+// adoptable reports if current, a resource not controlled by jsonServer, is taken over as a child.
+// Resources controlled by others are never adopted.
+func adoptable(jsonServer *examplecomv1.JsonServer, current client.Object) bool {
+	return adoptionPolicy(jsonServer) == examplecomv1.AdoptionPolicyAdopt && metav1.GetControllerOf(current) == nil
+}
+
+// adoptionConflict describes why current cannot be adopted, empty if it can. The selector of a Deployment is immutable,
+// a Deployment selecting other pods would never match the desired state.
+func adoptionConflict(desired client.Object, current client.Object) string {
+	if dd, ok := desired.(*v1.Deployment); ok {
+		cd := current.(*v1.Deployment)
+		if !equality.Semantic.DeepEqual(dd.Spec.Selector, cd.Spec.Selector) {
+			return fmt.Sprintf("%s %s has selector %s which cannot be changed, use adoptionPolicy rename",
+				objectType(current), client.ObjectKeyFromObject(current), metav1.FormatLabelSelector(cd.Spec.Selector))
+		}
+	}
+	return ""
+}
+
+// conflictMessage describes current, a resource which cannot be a child of the JsonServer.
+func conflictMessage(current client.Object) string {
+	if owner := metav1.GetControllerOf(current); owner != nil {
+		return fmt.Sprintf("%s %s is controlled by %s %s", objectType(current), client.ObjectKeyFromObject(current), owner.Kind, owner.Name)
+	}
+	return fmt.Sprintf("%s %s exists and is not owned by the JsonServer", objectType(current), client.ObjectKeyFromObject(current))
+}
+
+// setConflictCondition sets Conflict condition of jsonServer, it is removed if there are no conflicts.
+func setConflictCondition(jsonServer *examplecomv1.JsonServer, conflicts []string) {
+	if len(conflicts) == 0 {
+		meta.RemoveStatusCondition(&jsonServer.Status.Conditions, examplecomv1.ConditionConflict)
+		return
+	}
+	meta.SetStatusCondition(&jsonServer.Status.Conditions, metav1.Condition{
+		Type:               examplecomv1.ConditionConflict,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: jsonServer.Generation,
+		Reason:             "ResourceNotOwned",
+		Message:            fmt.Sprintf("%s (adoptionPolicy %s)", strings.Join(conflicts, "; "), adoptionPolicy(jsonServer)),
+	})
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corevV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"testing"
+)
+
+func Test_renamedChildName(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-xyz", UID: "5c3b1f0e-8a8e-4a36-9d4e-0d5b7d1b2c3a"}}
+	name := renamedChildName(jsonServer)
+	assert.True(t, strings.HasPrefix(name, "app-xyz-"))
+	assert.Equal(t, name, renamedChildName(jsonServer), "the same name is chosen again")
+
+	jsonServer.Name = strings.Repeat("a", 53) + "-" + strings.Repeat("b", 10)
+	assert.LessOrEqual(t, len(renamedChildName(jsonServer)), 63)
+	assert.NotContains(t, renamedChildName(jsonServer), "--")
+}
+
+func TestJsonServerReconciler_Reconcile_adoption(t *testing.T) {
+	controller := true
+	releaseOwner := metav1.OwnerReference{APIVersion: "example.org/v1", Kind: "Release", Name: "legacy", UID: "other", Controller: &controller}
+	tests := []struct {
+		name         string
+		policy       examplecomv1.AdoptionPolicy
+		owners       []metav1.OwnerReference
+		wantConflict bool
+		wantAdopted  bool
+		wantRenamed  bool
+	}{
+		{name: "fail by default", wantConflict: true},
+		{name: "fail", policy: examplecomv1.AdoptionPolicyFail, wantConflict: true},
+		{name: "adopt", policy: examplecomv1.AdoptionPolicyAdopt, wantAdopted: true},
+		{name: "adopt controlled by others", policy: examplecomv1.AdoptionPolicyAdopt, owners: []metav1.OwnerReference{releaseOwner}, wantConflict: true},
+		{name: "rename", policy: examplecomv1.AdoptionPolicyRename, wantRenamed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonServer := &examplecomv1.JsonServer{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-xyz", Namespace: "team-a", UID: types.UID("uid-" + strings.ReplaceAll(tt.name, " ", "-"))},
+				Spec:       examplecomv1.JsonServerSpec{JsonConfig: `{"people":[]}`, AdoptionPolicy: tt.policy},
+			}
+			defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+			existing := &corevV1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "app-xyz", Namespace: "team-a", OwnerReferences: tt.owners,
+					Labels: map[string]string{"helm.sh/chart": "legacy"}, Annotations: map[string]string{"meta.helm.sh/release-name": "legacy"}},
+				Data: map[string]string{"values.yaml": "from helm"},
+			}
+			r := newTestReconciler(jsonServer, existing)
+			c := r.Client
+			request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)}
+
+			// children are created by the first reconciliation
+			for i := 0; i < 2; i++ {
+				_, err := r.Reconcile(context.TODO(), request)
+				assert.NoError(t, err)
+			}
+			current := &examplecomv1.JsonServer{}
+			assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, current))
+			conflict := meta.FindStatusCondition(current.Status.Conditions, examplecomv1.ConditionConflict)
+			if tt.wantConflict {
+				if assert.NotNil(t, conflict) {
+					assert.Contains(t, conflict.Message, "ConfigMap team-a/app-xyz")
+				}
+				assert.Equal(t, examplecomv1.SyncStateError, string(current.Status.SyncState))
+			} else {
+				assert.Nil(t, conflict)
+			}
+			configMap := &corevV1.ConfigMap{}
+			assert.NoError(t, c.Get(context.TODO(), client.ObjectKeyFromObject(existing), configMap))
+			assert.Equal(t, tt.wantAdopted, metav1.IsControlledBy(configMap, current))
+			if tt.wantAdopted {
+				assert.Equal(t, `{"people":[]}`, configMap.Data[configMapField])
+				assert.Equal(t, "legacy", configMap.Labels["helm.sh/chart"], "labels of adopted resources are kept")
+				assert.Equal(t, "legacy", configMap.Annotations["meta.helm.sh/release-name"])
+				assert.NotEmpty(t, configMap.Labels[md5sumLabel])
+			} else {
+				assert.Equal(t, existing.Data, configMap.Data, "not adopted resources are not changed")
+			}
+			if tt.wantRenamed {
+				assert.NotEmpty(t, current.Status.ChildName)
+				assert.NotEqual(t, "app-xyz", current.ChildName())
+				assert.NoError(t, c.Get(context.TODO(), childKey(current), configMap))
+				assert.True(t, metav1.IsControlledBy(configMap, current))
+			} else {
+				assert.Empty(t, current.Status.ChildName)
+			}
+			// other children are created
+			deployment := &appsv1.Deployment{}
+			assert.NoError(t, c.Get(context.TODO(), childKey(current), deployment))
+			assert.Equal(t, current.ChildName(), deployment.Spec.Template.Spec.Volumes[0].ConfigMap.Name)
+		})
+	}
+}
+
+func TestJsonServerReconciler_Reconcile_adoptDeploymentSelector(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-xyz", Namespace: "team-a", UID: "uid-selector"},
+		Spec:       examplecomv1.JsonServerSpec{JsonConfig: `{"people":[]}`, AdoptionPolicy: examplecomv1.AdoptionPolicyAdopt},
+	}
+	defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+	selector := map[string]string{"app.kubernetes.io/name": "legacy"}
+	existing := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-xyz", Namespace: "team-a"},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: corevV1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: selector},
+				Spec:       corevV1.PodSpec{Containers: []corevV1.Container{{Name: containerName, Image: "legacy"}}},
+			},
+		},
+	}
+	r := newTestReconciler(jsonServer, existing)
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)}
+
+	// other children are created by the first reconciliation
+	for i := 0; i < 2; i++ {
+		_, err := r.Reconcile(context.TODO(), request)
+		assert.NoError(t, err)
+	}
+	current := &examplecomv1.JsonServer{}
+	assert.NoError(t, r.Get(context.TODO(), request.NamespacedName, current))
+	assert.Equal(t, examplecomv1.SyncStateError, string(current.Status.SyncState))
+	assert.Contains(t, current.Status.SyncMessage, "Deployment team-a/app-xyz has selector app.kubernetes.io/name=legacy which cannot be changed, use adoptionPolicy rename")
+	assert.True(t, meta.IsStatusConditionTrue(current.Status.Conditions, examplecomv1.ConditionConflict))
+	deployment := &appsv1.Deployment{}
+	assert.NoError(t, r.Get(context.TODO(), request.NamespacedName, deployment))
+	assert.Nil(t, metav1.GetControllerOf(deployment), "the Deployment is not adopted")
+	assert.Equal(t, "legacy", deployment.Spec.Template.Spec.Containers[0].Image)
+}
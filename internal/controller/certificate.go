@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+const defaultIssuerGroup = "cert-manager.io"
+
+// certificateGVK is cert-manager Certificate, it is handled as unstructured so cert-manager is not a dependency of the operator
+var certificateGVK = schema.GroupVersionKind{Group: "cert-manager.io", Version: "v1", Kind: "Certificate"}
+
+// certManagerInstalled is set on start of the operator if the Certificate CRD is present in the cluster,
+// it is detected again by reconciliation when cert-manager is installed later
+var certManagerInstalled = false
+
+// certManagerDetectionInterval limits discovery requests when cert-manager is not installed
+const certManagerDetectionInterval = time.Minute
+
+// detectCertManager checks if cert-manager CRDs are installed.
+func detectCertManager(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(certificateGVK.GroupKind(), certificateGVK.Version)
+	return err == nil
+}
+
+func newCertificate() *unstructured.Unstructured {
+	certificate := &unstructured.Unstructured{}
+	certificate.SetGroupVersionKind(certificateGVK)
+	return certificate
+}
+
+func createJsonServerCertificateResource(jsonServer *examplecomv1.JsonServer) client.Object {
+	certificate := newCertificate()
+	certificate.SetName(jsonServer.ChildName())
+	certificate.SetNamespace(jsonServer.Namespace)
+	certificate.SetOwnerReferences(createOwnerReferences(jsonServer, false))
+	issuer := map[string]interface{}{}
+	if ref := jsonServer.Spec.TLS.IssuerRef; ref != nil {
+		kind := ref.Kind
+		if kind == "" {
+			kind = "Issuer"
+		}
+		group := ref.Group
+		if group == "" {
+			group = defaultIssuerGroup
+		}
+		issuer = map[string]interface{}{"name": ref.Name, "kind": kind, "group": group}
+	}
+	dnsNames := make([]interface{}, 0)
+	for _, name := range serviceDNSNames(jsonServer) {
+		dnsNames = append(dnsNames, name)
+	}
+	certificate.Object["spec"] = map[string]interface{}{
+		"secretName": tlsSecretName(jsonServer),
+		"dnsNames":   dnsNames,
+		"issuerRef":  issuer,
+	}
+	return certificate
+}
+
+// redetectCertManager checks again if cert-manager is installed. Certificates of a cert-manager installed after the start
+// are created, but their changes are noticed only by periodic reconciliation until the operator is restarted.
+func (r *JsonServerReconciler) redetectCertManager() {
+	if certManagerInstalled || r.restMapper == nil || time.Since(r.certManagerDetectedAt) < certManagerDetectionInterval {
+		return
+	}
+	r.certManagerDetectedAt = time.Now()
+	certManagerInstalled = detectCertManager(r.restMapper)
+}
+
+// certificateUnavailable returns why the certificate of jsonServer cannot be issued, empty if it can.
+// Pods would wait for the Secret of the certificate forever, so the Deployment is not changed then.
+func certificateUnavailable(jsonServer *examplecomv1.JsonServer) string {
+	if tls := jsonServer.Spec.TLS; tls != nil && tls.IssuerRef != nil && !certManagerInstalled {
+		return "tls.issuerRef requires cert-manager, the Certificate CRD is not installed"
+	}
+	return ""
+}
+
+// certificateRequired reports if a Certificate is created by the operator, it requires cert-manager.
+func certificateRequired(jsonServer *examplecomv1.JsonServer) bool {
+	return certManagerInstalled && jsonServer.Spec.TLS != nil && jsonServer.Spec.TLS.IssuerRef != nil
+}
+
+// tlsSecretName returns the name of the Secret with the certificate, the one created by cert-manager if the Secret is not given.
+func tlsSecretName(jsonServer *examplecomv1.JsonServer) string {
+	if jsonServer.Spec.TLS.SecretName != "" {
+		return jsonServer.Spec.TLS.SecretName
+	}
+	return jsonServer.ChildName() + "-tls"
+}
+
+func serviceDNSNames(jsonServer *examplecomv1.JsonServer) []string {
+	name := jsonServer.ChildName()
+	return []string{
+		name,
+		fmt.Sprintf("%s.%s", name, jsonServer.Namespace),
+		fmt.Sprintf("%s.%s.svc", name, jsonServer.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", name, jsonServer.Namespace),
+	}
+}
+
+// certificateCondition returns CertificateReady condition, nil if TLS is not enabled.
+func (r *JsonServerReconciler) certificateCondition(ctx context.Context, jsonServer *examplecomv1.JsonServer) (*metav1.Condition, error) {
+	tls := jsonServer.Spec.TLS
+	if tls == nil {
+		return nil, nil
+	}
+	condition := &metav1.Condition{Type: examplecomv1.ConditionCertificateReady, ObservedGeneration: jsonServer.Generation}
+	switch {
+	case tls.SecretName != "":
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "SecretProvided"
+		condition.Message = fmt.Sprintf("certificate is provided by Secret %s", tls.SecretName)
+	case !certManagerInstalled:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "CertManagerNotInstalled"
+		condition.Message = "issuerRef requires cert-manager, Certificate CRD was not found when the operator started"
+	default:
+		certificate := newCertificate()
+		err := r.Get(ctx, childKey(jsonServer), certificate)
+		if k8errors.IsNotFound(err) {
+			condition.Status = metav1.ConditionFalse
+			condition.Reason = "CertificateMissing"
+			condition.Message = "Certificate has not been created yet"
+			return condition, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get Certificate %s", childKey(jsonServer))
+		}
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "Pending"
+		condition.Message = "Certificate has no Ready condition"
+		conditions, _, _ := unstructured.NestedSlice(certificate.Object, "status", "conditions")
+		for _, c := range conditions {
+			certCondition, ok := c.(map[string]interface{})
+			if !ok || certCondition["type"] != "Ready" {
+				continue
+			}
+			status, _ := certCondition["status"].(string)
+			condition.Status = metav1.ConditionStatus(status)
+			condition.Reason, _ = certCondition["reason"].(string)
+			condition.Message, _ = certCondition["message"].(string)
+			if condition.Reason == "" {
+				condition.Reason = "Unknown"
+			}
+		}
+	}
+	return condition, nil
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"testing"
+)
+
+func Test_createJsonServerCertificateResource(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{TLS: &examplecomv1.TLSSpec{IssuerRef: &examplecomv1.IssuerReference{Name: "ca"}}}}
+	jsonServer.Name = "app-test"
+	jsonServer.Namespace = "mocks"
+	certificate := createJsonServerCertificateResource(jsonServer).(*unstructured.Unstructured)
+	secretName, _, _ := unstructured.NestedString(certificate.Object, "spec", "secretName")
+	assert.Equal(t, "app-test-tls", secretName)
+	dnsNames, _, _ := unstructured.NestedStringSlice(certificate.Object, "spec", "dnsNames")
+	assert.Contains(t, dnsNames, "app-test.mocks.svc")
+	issuer, _, _ := unstructured.NestedStringMap(certificate.Object, "spec", "issuerRef")
+	assert.Equal(t, map[string]string{"name": "ca", "kind": "Issuer", "group": "cert-manager.io"}, issuer)
+	assert.Equal(t, "Certificate", objectType(certificate))
+
+	current := certificate.DeepCopy()
+	assert.NoError(t, unstructured.SetNestedField(current.Object, "ECDSA", "spec", "privateKey", "algorithm"))
+	assert.Empty(t, findResourceDifferences(certificate, current), "fields defaulted by cert-manager are not compared")
+	assert.NoError(t, unstructured.SetNestedField(current.Object, "other", "spec", "secretName"))
+	assert.Equal(t, []string{"secretName"}, findResourceDifferences(certificate, current))
+}
+
+func TestJsonServerReconciler_certificateCondition(t *testing.T) {
+	defer func(installed bool) { certManagerInstalled = installed }(certManagerInstalled)
+	certManagerInstalled = true
+	scheme := runtime.NewScheme()
+	scheme.AddKnownTypeWithName(certificateGVK, &unstructured.Unstructured{})
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{TLS: &examplecomv1.TLSSpec{IssuerRef: &examplecomv1.IssuerReference{Name: "ca"}}}}
+	jsonServer.Name = "app-test"
+	jsonServer.Namespace = "mocks"
+	readyCertificate := createJsonServerCertificateResource(jsonServer).(*unstructured.Unstructured)
+	assert.NoError(t, unstructured.SetNestedSlice(readyCertificate.Object, []interface{}{
+		map[string]interface{}{"type": "Ready", "status": "True", "reason": "Ready", "message": "Certificate is up to date"},
+	}, "status", "conditions"))
+	tests := []struct {
+		name        string
+		objects     []client.Object
+		tls         *examplecomv1.TLSSpec
+		wantStatus  metav1.ConditionStatus
+		wantReason  string
+		wantMissing bool
+	}{
+		{name: "no tls", wantMissing: true},
+		{name: "secret", tls: &examplecomv1.TLSSpec{SecretName: "cert"}, wantStatus: metav1.ConditionTrue, wantReason: "SecretProvided"},
+		{name: "missing certificate", tls: jsonServer.Spec.TLS, wantStatus: metav1.ConditionFalse, wantReason: "CertificateMissing"},
+		{name: "ready certificate", tls: jsonServer.Spec.TLS, objects: []client.Object{readyCertificate}, wantStatus: metav1.ConditionTrue, wantReason: "Ready"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &JsonServerReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).Build()}
+			js := jsonServer.DeepCopy()
+			js.Spec.TLS = tt.tls
+			condition, err := r.certificateCondition(context.TODO(), js)
+			assert.NoError(t, err)
+			if tt.wantMissing {
+				assert.Nil(t, condition)
+				return
+			}
+			assert.Equal(t, tt.wantStatus, condition.Status)
+			assert.Equal(t, tt.wantReason, condition.Reason)
+		})
+	}
+}
+
+func TestJsonServerReconciler_validateResources_certManagerNotInstalled(t *testing.T) {
+	defer func(installed bool) { certManagerInstalled = installed }(certManagerInstalled)
+	certManagerInstalled = false
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{JsonConfig: `{}`, TLS: &examplecomv1.TLSSpec{IssuerRef: &examplecomv1.IssuerReference{Name: "ca"}}}}
+	jsonServer.Name = "app-test"
+	jsonServer.Namespace = "mocks"
+	r := newTestReconciler()
+	fixActions, _, criticalErrors, err := r.validateResources(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Len(t, criticalErrors, 1)
+	for _, action := range fixActions {
+		assert.NotContains(t, action.Reason(), "Deployment", "pods would wait for the certificate Secret forever")
+	}
+}
@@ -4,18 +4,51 @@ import (
 	"fmt"
 	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
 	v1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corevV1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
 )
 
 const (
 	configMapField = "db.json"
 	md5sumLabel    = "md5sum"
 	port           = 3000
+	defaultImage   = "backplane/json-server"
+	containerName  = "json-server"
+
+	defaultTargetCPUUtilization int32 = 80
 )
 
+// childResource is a resource owned by JsonServer
+type childResource struct {
+	// create returns the desired state of the resource
+	create func(*examplecomv1.JsonServer) client.Object
+	// required reports if the resource should exist, nil means always
+	required func(*examplecomv1.JsonServer) bool
+	// available reports if the kind of the resource is known to the cluster, nil means always
+	available func() bool
+}
+
+// childResources returns resources owned by JsonServer, built with settings of the reconciler.
+func (r *JsonServerReconciler) childResources() []childResource {
+	return []childResource{
+		{create: createJsonServerConfigMapResource},
+		{create: r.createJsonServerDeploymentResource},
+		{create: createJsonServerServiceResource},
+		{create: createJsonServerHorizontalPodAutoscalerResource, required: autoscalingEnabled},
+		{create: createJsonServerPodDisruptionBudgetResource, required: disruptionBudgetRequired},
+		{create: r.createJsonServerNetworkPolicyResource, required: networkPolicyRequired},
+		{create: createJsonServerCertificateResource, required: certificateRequired, available: func() bool { return certManagerInstalled }},
+		{create: createJsonServerServiceMonitorResource, required: serviceMonitorRequired, available: func() bool { return serviceMonitorInstalled }},
+	}
+}
+
 func createOwnerReferences(jsonServer *examplecomv1.JsonServer, blockOwnerDeletion bool) []metav1.OwnerReference {
 	ownerReferences := make([]metav1.OwnerReference, 0)
 	ownerRef := metav1.OwnerReference{
@@ -31,9 +64,9 @@ func createOwnerReferences(jsonServer *examplecomv1.JsonServer, blockOwnerDeleti
 
 func createJsonServerConfigMapResource(jsonServer *examplecomv1.JsonServer) client.Object {
 	jsonContent := jsonServer.Spec.JsonConfig
-	return &corevV1.ConfigMap{
+	configMap := &corevV1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            jsonServer.Name,
+			Name:            jsonServer.ChildName(),
 			Namespace:       jsonServer.Namespace,
 			OwnerReferences: createOwnerReferences(jsonServer, false),
 			Labels: map[string]string{
@@ -44,21 +77,25 @@ func createJsonServerConfigMapResource(jsonServer *examplecomv1.JsonServer) clie
 			configMapField: jsonContent,
 		},
 	}
+	if sidecarRequired(jsonServer) {
+		configMap.Data[sidecarConfigField] = sidecarConfigJson(jsonServer)
+	}
+	return configMap
 }
 
-func createJsonServerDeploymentResource(jsonServer *examplecomv1.JsonServer) client.Object {
-	labels := map[string]string{"app": jsonServer.Name}
+func (r *JsonServerReconciler) createJsonServerDeploymentResource(jsonServer *examplecomv1.JsonServer) client.Object {
+	labels := map[string]string{"app": jsonServer.ChildName()}
 	for key, val := range jsonServer.Labels {
 		labels[key] = val
 	}
 	deployment := &v1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            jsonServer.Name,
+			Name:            jsonServer.ChildName(),
 			Namespace:       jsonServer.Namespace,
 			OwnerReferences: createOwnerReferences(jsonServer, false),
 		},
 		Spec: v1.DeploymentSpec{
-			Replicas: jsonServer.Spec.Replicas,
+			Replicas: desiredReplicas(jsonServer),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
@@ -68,9 +105,12 @@ func createJsonServerDeploymentResource(jsonServer *examplecomv1.JsonServer) cli
 				},
 				Spec: corevV1.PodSpec{
 					Containers: []corevV1.Container{{
-						Image: "backplane/json-server",
-						Name:  "json-server",
-						Args:  []string{fmt.Sprintf("/data/%s", configMapField)},
+						Image:          jsonServerImage(jsonServer),
+						Name:           containerName,
+						Args:           []string{fmt.Sprintf("/data/%s", configMapField)},
+						Resources:      jsonServerContainerResources(jsonServer),
+						LivenessProbe:  withProbeDefaults(jsonServer.Spec.LivenessProbe),
+						ReadinessProbe: withProbeDefaults(jsonServer.Spec.ReadinessProbe),
 						Ports: []corevV1.ContainerPort{{
 							Name:          "http",
 							ContainerPort: port,
@@ -86,7 +126,7 @@ func createJsonServerDeploymentResource(jsonServer *examplecomv1.JsonServer) cli
 						Name: "json-config",
 						VolumeSource: corevV1.VolumeSource{
 							ConfigMap: &corevV1.ConfigMapVolumeSource{
-								LocalObjectReference: corevV1.LocalObjectReference{Name: jsonServer.Name},
+								LocalObjectReference: corevV1.LocalObjectReference{Name: jsonServer.ChildName()},
 							},
 						},
 					}},
@@ -94,17 +134,41 @@ func createJsonServerDeploymentResource(jsonServer *examplecomv1.JsonServer) cli
 			},
 		},
 	}
+	if sidecarRequired(jsonServer) {
+		image := r.SidecarImage
+		if image == "" {
+			image = DefaultSidecarImage
+		}
+		injectSidecar(deployment, jsonServer, image)
+	}
 	return deployment
 }
 
 func createJsonServerServiceResource(jsonServer *examplecomv1.JsonServer) client.Object {
+	serviceType := corevV1.ServiceTypeClusterIP
+	var annotations map[string]string
+	if settings := jsonServer.Spec.Service; settings != nil {
+		if settings.Type != "" {
+			serviceType = settings.Type
+		}
+		if len(settings.Annotations) > 0 {
+			annotations = map[string]string{managedAnnotationsAnnotation: annotationKeys(settings.Annotations)}
+			for key, val := range settings.Annotations {
+				annotations[key] = val
+			}
+		}
+	}
 	service := &corevV1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:            jsonServer.Name,
+			Name:            jsonServer.ChildName(),
 			Namespace:       jsonServer.Namespace,
 			OwnerReferences: createOwnerReferences(jsonServer, false),
+			Annotations:     annotations,
+			// selected by the ServiceMonitor
+			Labels: map[string]string{"app": jsonServer.ChildName()},
 		},
 		Spec: corevV1.ServiceSpec{
+			Type: serviceType,
 			Ports: []corevV1.ServicePort{{
 				Name:     "http",
 				Protocol: "TCP",
@@ -116,9 +180,287 @@ func createJsonServerServiceResource(jsonServer *examplecomv1.JsonServer) client
 				},
 			}},
 			Selector: map[string]string{
-				"app": jsonServer.Name,
+				"app": jsonServer.ChildName(),
 			},
 		},
 	}
+	if metricsEnabled(jsonServer) {
+		service.Spec.Ports = append(service.Spec.Ports, corevV1.ServicePort{
+			Name:       "metrics",
+			Protocol:   "TCP",
+			Port:       sidecarMetricsPort,
+			TargetPort: intstr.FromString("metrics"),
+		})
+	}
 	return service
 }
+
+// managedAnnotationsAnnotation lists annotations of a Service set from the JsonServer, so removed ones are detected
+const managedAnnotationsAnnotation = "jsonserver.example.com/managed-annotations"
+
+func annotationKeys(annotations map[string]string) string {
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+func jsonServerImage(jsonServer *examplecomv1.JsonServer) string {
+	if jsonServer.Spec.Image != "" {
+		return jsonServer.Spec.Image
+	}
+	return defaultImage
+}
+
+func jsonServerContainerResources(jsonServer *examplecomv1.JsonServer) corevV1.ResourceRequirements {
+	if jsonServer.Spec.Resources != nil {
+		return *jsonServer.Spec.Resources.DeepCopy()
+	}
+	return corevV1.ResourceRequirements{}
+}
+
+// withProbeDefaults returns a copy of the probe with defaults the API server would set, so it can be compared with the current state.
+func withProbeDefaults(probe *corevV1.Probe) *corevV1.Probe {
+	if probe == nil {
+		return nil
+	}
+	p := probe.DeepCopy()
+	if p.TimeoutSeconds == 0 {
+		p.TimeoutSeconds = 1
+	}
+	if p.PeriodSeconds == 0 {
+		p.PeriodSeconds = 10
+	}
+	if p.SuccessThreshold == 0 {
+		p.SuccessThreshold = 1
+	}
+	if p.FailureThreshold == 0 {
+		p.FailureThreshold = 3
+	}
+	if p.HTTPGet != nil && p.HTTPGet.Scheme == "" {
+		p.HTTPGet.Scheme = corevV1.URISchemeHTTP
+	}
+	return p
+}
+
+// keepImmutableFields copies fields assigned by the API server from current to desired object, so the desired one can be used for an update.
+func keepImmutableFields(desired client.Object, current client.Object) {
+	desired.SetResourceVersion(current.GetResourceVersion())
+	// labels and annotations set by others, e.g. on an adopted resource, are kept
+	desired.SetLabels(mergeMissing(desired.GetLabels(), current.GetLabels()))
+	desired.SetAnnotations(mergeMissing(desired.GetAnnotations(), current.GetAnnotations()))
+	if ds, ok := desired.(*corevV1.Service); ok {
+		cs := current.(*corevV1.Service)
+		ds.Spec.ClusterIP = cs.Spec.ClusterIP
+		ds.Spec.ClusterIPs = cs.Spec.ClusterIPs
+		for i := range ds.Spec.Ports {
+			for _, cp := range cs.Spec.Ports {
+				if cp.Name == ds.Spec.Ports[i].Name && ds.Spec.Type != corevV1.ServiceTypeClusterIP {
+					ds.Spec.Ports[i].NodePort = cp.NodePort
+				}
+			}
+		}
+	}
+}
+
+// mergeMissing returns values with keys of other which are missing in values.
+func mergeMissing(values map[string]string, other map[string]string) map[string]string {
+	for key, val := range other {
+		if _, ok := values[key]; !ok {
+			if values == nil {
+				values = make(map[string]string)
+			}
+			values[key] = val
+		}
+	}
+	return values
+}
+
+func createJsonServerHorizontalPodAutoscalerResource(jsonServer *examplecomv1.JsonServer) client.Object {
+	hpa := &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jsonServer.ChildName(),
+			Namespace:       jsonServer.Namespace,
+			OwnerReferences: createOwnerReferences(jsonServer, false),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			// scale JsonServer (not the Deployment), so the replicas are propagated by the operator
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: examplecomv1.GroupVersion.String(),
+				Kind:       "JsonServer",
+				Name:       jsonServer.Name,
+			},
+		},
+	}
+	as := jsonServer.Spec.Autoscaling
+	if as == nil {
+		return hpa
+	}
+	hpa.Spec.MinReplicas = autoscalingMinReplicas(as)
+	hpa.Spec.MaxReplicas = as.MaxReplicas
+	targetCPU := as.TargetCPUUtilizationPercentage
+	if targetCPU == nil && len(as.Metrics) == 0 {
+		defaultTargetCPU := defaultTargetCPUUtilization
+		targetCPU = &defaultTargetCPU
+	}
+	if targetCPU != nil {
+		hpa.Spec.Metrics = append(hpa.Spec.Metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: corevV1.ResourceCPU,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: targetCPU,
+				},
+			},
+		})
+	}
+	for _, metric := range as.Metrics {
+		hpa.Spec.Metrics = append(hpa.Spec.Metrics, *metric.DeepCopy())
+	}
+	return hpa
+}
+
+func autoscalingEnabled(jsonServer *examplecomv1.JsonServer) bool {
+	return jsonServer.Spec.Autoscaling != nil
+}
+
+func autoscalingMinReplicas(as *examplecomv1.AutoscalingSpec) *int32 {
+	minReplicas := int32(1)
+	if as.MinReplicas != nil {
+		minReplicas = *as.MinReplicas
+	}
+	return &minReplicas
+}
+
+// desiredReplicas returns replicas of the Deployment.
+// With autoscaling enabled the autoscaler sets spec.replicas through the scale subresource,
+// a value outside of autoscaling limits (e.g. re-applied manifest) is clamped instead of being propagated to the Deployment.
+func desiredReplicas(jsonServer *examplecomv1.JsonServer) *int32 {
+	as := jsonServer.Spec.Autoscaling
+	if as == nil || jsonServer.Spec.Replicas == nil {
+		return jsonServer.Spec.Replicas
+	}
+	replicas := *jsonServer.Spec.Replicas
+	if minReplicas := *autoscalingMinReplicas(as); replicas < minReplicas {
+		replicas = minReplicas
+	}
+	if replicas > as.MaxReplicas {
+		replicas = as.MaxReplicas
+	}
+	return &replicas
+}
+
+func createJsonServerPodDisruptionBudgetResource(jsonServer *examplecomv1.JsonServer) client.Object {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jsonServer.ChildName(),
+			Namespace:       jsonServer.Namespace,
+			OwnerReferences: createOwnerReferences(jsonServer, false),
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": jsonServer.ChildName()},
+			},
+		},
+	}
+	if db := jsonServer.Spec.DisruptionBudget; db != nil && (db.MinAvailable != nil || db.MaxUnavailable != nil) {
+		pdb.Spec.MinAvailable = db.MinAvailable
+		pdb.Spec.MaxUnavailable = db.MaxUnavailable
+	} else {
+		maxUnavailable := intstr.FromInt(1)
+		pdb.Spec.MaxUnavailable = &maxUnavailable
+	}
+	return pdb
+}
+
+// disruptionBudgetRequired reports if there is more than one replica to protect, a single replica would block node drains
+func disruptionBudgetRequired(jsonServer *examplecomv1.JsonServer) bool {
+	replicas := desiredReplicas(jsonServer)
+	return replicas != nil && *replicas > 1
+}
+
+func (r *JsonServerReconciler) createJsonServerNetworkPolicyResource(jsonServer *examplecomv1.JsonServer) client.Object {
+	policy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jsonServer.ChildName(),
+			Namespace:       jsonServer.Namespace,
+			OwnerReferences: createOwnerReferences(jsonServer, false),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": jsonServer.ChildName()},
+			},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{},
+		},
+	}
+	tcp := corevV1.ProtocolTCP
+	if !jsonServer.AccessRestricted() {
+		// json-server is open to all clients, the policy only keeps the admin port of the sidecar for the operator
+		httpPort := intstr.FromString("http")
+		policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &httpPort}},
+		})
+	} else {
+		access := jsonServer.Spec.Access
+		peers := make([]networkingv1.NetworkPolicyPeer, 0)
+		if !access.DefaultDeny {
+			// all pods from the namespace of JsonServer
+			peers = append(peers, networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{}})
+		}
+		for _, allowed := range access.AllowFrom {
+			peer := networkingv1.NetworkPolicyPeer{
+				NamespaceSelector: allowed.NamespaceSelector.DeepCopy(),
+				PodSelector:       allowed.PodSelector.DeepCopy(),
+			}
+			if allowed.CIDR != "" {
+				peer.IPBlock = &networkingv1.IPBlock{CIDR: allowed.CIDR, Except: allowed.Except}
+			}
+			peers = append(peers, peer)
+		}
+		if len(peers) > 0 {
+			httpPort := intstr.FromString("http")
+			policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+				Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &httpPort}},
+				From:  peers,
+			})
+		}
+	}
+	operatorPorts := make([]networkingv1.NetworkPolicyPort, 0)
+	if sidecarRequired(jsonServer) {
+		// the operator reads stats of the sidecar and data of json-server for snapshots
+		adminPort := intstr.FromString("admin")
+		operatorPorts = append(operatorPorts, networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &adminPort})
+	}
+	if jsonServer.AccessRestricted() && ((snapshotRequired(jsonServer) && !sidecarRequired(jsonServer)) || jsonServer.Spec.Idle != nil) {
+		// data for snapshots is read from json-server without the sidecar, the activator forwards requests of idle JsonServers
+		// only from callers allowed by spec.access
+		httpPort := intstr.FromString("http")
+		operatorPorts = append(operatorPorts, networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &httpPort})
+	}
+	if metricsEnabled(jsonServer) {
+		// Prometheus may run in any namespace, metrics contain only request counters
+		metricsPort := intstr.FromString("metrics")
+		policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &metricsPort}},
+			From:  []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{}}},
+		})
+	}
+	if len(operatorPorts) > 0 && r.OperatorNamespace != "" {
+		policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+			Ports: operatorPorts,
+			From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"kubernetes.io/metadata.name": r.OperatorNamespace},
+			}}},
+		})
+	}
+	return policy
+}
+
+// networkPolicyRequired reports if ingress of json-server pods is restricted, the admin port of the sidecar always is.
+func networkPolicyRequired(jsonServer *examplecomv1.JsonServer) bool {
+	return jsonServer.AccessRestricted() || sidecarRequired(jsonServer)
+}
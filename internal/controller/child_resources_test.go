@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+)
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
+
+func Test_desiredReplicas(t *testing.T) {
+	autoscaling := &examplecomv1.AutoscalingSpec{MinReplicas: int32Ptr(2), MaxReplicas: 5}
+	tests := []struct {
+		replicas    *int32
+		autoscaling *examplecomv1.AutoscalingSpec
+		want        *int32
+	}{
+		{replicas: nil, autoscaling: nil, want: nil},
+		{replicas: int32Ptr(7), autoscaling: nil, want: int32Ptr(7)},
+		{replicas: int32Ptr(3), autoscaling: autoscaling, want: int32Ptr(3)},
+		{replicas: int32Ptr(1), autoscaling: autoscaling, want: int32Ptr(2)},
+		{replicas: int32Ptr(9), autoscaling: autoscaling, want: int32Ptr(5)},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("desiredReplicas(%v, %v)", tt.replicas, tt.autoscaling), func(t *testing.T) {
+			jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{Replicas: tt.replicas, Autoscaling: tt.autoscaling}}
+			assert.Equal(t, tt.want, desiredReplicas(jsonServer))
+		})
+	}
+}
+
+func Test_createJsonServerHorizontalPodAutoscalerResource(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{Autoscaling: &examplecomv1.AutoscalingSpec{MaxReplicas: 4}}}
+	hpa := createJsonServerHorizontalPodAutoscalerResource(jsonServer).(*autoscalingv2.HorizontalPodAutoscaler)
+	assert.Equal(t, "JsonServer", hpa.Spec.ScaleTargetRef.Kind)
+	assert.Equal(t, int32(1), *hpa.Spec.MinReplicas)
+	assert.Equal(t, int32(4), hpa.Spec.MaxReplicas)
+	assert.Len(t, hpa.Spec.Metrics, 1)
+	assert.Equal(t, defaultTargetCPUUtilization, *hpa.Spec.Metrics[0].Resource.Target.AverageUtilization)
+}
+
+func Test_createJsonServerPodDisruptionBudgetResource(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{Replicas: int32Ptr(3)}}
+	assert.True(t, disruptionBudgetRequired(jsonServer))
+	pdb := createJsonServerPodDisruptionBudgetResource(jsonServer).(*policyv1.PodDisruptionBudget)
+	assert.Equal(t, intstr.FromInt(1), *pdb.Spec.MaxUnavailable)
+	assert.Nil(t, pdb.Spec.MinAvailable)
+
+	minAvailable := intstr.FromString("50%")
+	jsonServer.Spec.DisruptionBudget = &examplecomv1.DisruptionBudgetSpec{MinAvailable: &minAvailable}
+	pdb = createJsonServerPodDisruptionBudgetResource(jsonServer).(*policyv1.PodDisruptionBudget)
+	assert.Equal(t, minAvailable, *pdb.Spec.MinAvailable)
+	assert.Nil(t, pdb.Spec.MaxUnavailable)
+
+	jsonServer.Spec.Replicas = int32Ptr(1)
+	assert.False(t, disruptionBudgetRequired(jsonServer))
+}
+
+func Test_createJsonServerNetworkPolicyResource(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{Access: &examplecomv1.AccessSpec{DefaultDeny: true}}}
+	assert.True(t, networkPolicyRequired(jsonServer))
+	r := &JsonServerReconciler{}
+	policy := r.createJsonServerNetworkPolicyResource(jsonServer).(*networkingv1.NetworkPolicy)
+	assert.Empty(t, policy.Spec.Ingress, "deny all")
+
+	jsonServer.Spec.Access = &examplecomv1.AccessSpec{AllowFrom: []examplecomv1.AccessPeer{{CIDR: "10.0.0.0/8"}}}
+	policy = r.createJsonServerNetworkPolicyResource(jsonServer).(*networkingv1.NetworkPolicy)
+	assert.Len(t, policy.Spec.Ingress, 1)
+	assert.Len(t, policy.Spec.Ingress[0].From, 2, "own namespace and cidr")
+	assert.Equal(t, "10.0.0.0/8", policy.Spec.Ingress[0].From[1].IPBlock.CIDR)
+
+	jsonServer.Spec.Access = &examplecomv1.AccessSpec{}
+	assert.False(t, networkPolicyRequired(jsonServer))
+
+	// the admin port of the sidecar is open only to the operator also without access restrictions
+	jsonServer.Spec.Auth = &examplecomv1.AuthSpec{AnonymousRead: true}
+	assert.True(t, networkPolicyRequired(jsonServer))
+	r.OperatorNamespace = "operator-system"
+	policy = r.createJsonServerNetworkPolicyResource(jsonServer).(*networkingv1.NetworkPolicy)
+	if assert.Len(t, policy.Spec.Ingress, 2) {
+		assert.Equal(t, "http", policy.Spec.Ingress[0].Ports[0].Port.StrVal)
+		assert.Empty(t, policy.Spec.Ingress[0].From, "all clients")
+		assert.Equal(t, "admin", policy.Spec.Ingress[1].Ports[0].Port.StrVal)
+		assert.Equal(t, "operator-system", policy.Spec.Ingress[1].From[0].NamespaceSelector.MatchLabels["kubernetes.io/metadata.name"])
+	}
+}
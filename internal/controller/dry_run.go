@@ -0,0 +1,35 @@
+package controller
+
+import (
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"strconv"
+)
+
+// DryRun makes the operator plan changes of JsonServers without executing them, overridden by examplecomv1.DryRunAnnotation.
+var DryRun = false
+
+// dryRunEnabled reports if changes of jsonServer are only planned.
+func dryRunEnabled(jsonServer *examplecomv1.JsonServer) bool {
+	if value, err := strconv.ParseBool(jsonServer.Annotations[examplecomv1.DryRunAnnotation]); err == nil {
+		return value
+	}
+	return DryRun
+}
+
+// planAction records a change that is not executed in dry-run mode, it is reported in status.
+func planAction(jsonServer *examplecomv1.JsonServer, reason string, description string) {
+	jsonServer.Status.PlannedActions = append(jsonServer.Status.PlannedActions, fmt.Sprintf("%s: %s", reason, description))
+}
+
+// emitPlanEvents emits an event for each planned action when the plan differs from the previous one,
+// so the same plan is not reported on every reconciliation.
+func (r *JsonServerReconciler) emitPlanEvents(jsonServer *examplecomv1.JsonServer, previous []string) {
+	if equality.Semantic.DeepEqual(previous, jsonServer.Status.PlannedActions) {
+		return
+	}
+	for _, action := range jsonServer.Status.PlannedActions {
+		r.Recorder.Event(jsonServer, "Normal", "PlannedAction", action)
+	}
+}
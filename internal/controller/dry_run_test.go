@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corevV1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"testing"
+)
+
+func Test_dryRunEnabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		flag       bool
+		annotation string
+		want       bool
+	}{
+		{name: "disabled", want: false},
+		{name: "flag", flag: true, want: true},
+		{name: "annotation", annotation: "true", want: true},
+		{name: "annotation overrides flag", flag: true, annotation: "false", want: false},
+		{name: "invalid annotation", flag: true, annotation: "maybe", want: true},
+	}
+	defer func(dryRun bool) { DryRun = dryRun }(DryRun)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			DryRun = tt.flag
+			jsonServer := &examplecomv1.JsonServer{}
+			if tt.annotation != "" {
+				jsonServer.Annotations = map[string]string{examplecomv1.DryRunAnnotation: tt.annotation}
+			}
+			assert.Equal(t, tt.want, dryRunEnabled(jsonServer))
+		})
+	}
+}
+
+func TestJsonServerReconciler_Reconcile_dryRun(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-planned", Namespace: "team-a", Annotations: map[string]string{examplecomv1.DryRunAnnotation: "true"}},
+		Spec:       examplecomv1.JsonServerSpec{JsonConfig: `{"people":[]}`},
+	}
+	defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+	r := newTestReconciler(jsonServer)
+	c := r.Client
+	recorder := r.Recorder.(*record.FakeRecorder)
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)}
+
+	_, err := r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	err = c.Get(context.TODO(), request.NamespacedName, &appsv1.Deployment{})
+	assert.True(t, k8errors.IsNotFound(err), "deployment must not be created in dry-run mode")
+	planned := &examplecomv1.JsonServer{}
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, planned))
+	assert.Equal(t, examplecomv1.SyncStateNotSynced, string(planned.Status.SyncState))
+	reasons := make([]string, 0)
+	for _, action := range planned.Status.PlannedActions {
+		reasons = append(reasons, strings.SplitN(action, ":", 2)[0])
+	}
+	assert.Contains(t, reasons, "Create-Deployment")
+	assert.Contains(t, reasons, "Create-ConfigMap")
+	events := len(recorder.Events)
+	assert.Equal(t, len(planned.Status.PlannedActions), events, "an event per planned action")
+
+	// the same plan is not reported again
+	_, err = r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.Equal(t, events, len(recorder.Events))
+
+	// the plan is executed and cleared when the dry-run mode is turned off
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, planned))
+	planned.Annotations[examplecomv1.DryRunAnnotation] = "false"
+	assert.NoError(t, c.Update(context.TODO(), planned))
+	_, err = r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, &appsv1.Deployment{}))
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, planned))
+	assert.Empty(t, planned.Status.PlannedActions)
+}
+
+func TestJsonServerReconciler_Reconcile_dryRunSnapshot(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-planned", Namespace: "team-a", Annotations: map[string]string{examplecomv1.DryRunAnnotation: "true"}},
+		Spec: examplecomv1.JsonServerSpec{
+			JsonConfig:         `{"people":[]}`,
+			DeletionProtection: &examplecomv1.DeletionProtectionSpec{Snapshot: true},
+		},
+	}
+	defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+	r := newTestReconciler(jsonServer)
+	c := r.Client
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)}
+
+	_, err := r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	planned := &examplecomv1.JsonServer{}
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, planned))
+	assert.Empty(t, planned.Finalizers, "the finalizer must not be added in dry-run mode")
+	assert.Contains(t, planned.Status.PlannedActions, "AddFinalizer-JsonServer: "+snapshotFinalizer)
+
+	// a deleted JsonServer is kept without a snapshot
+	planned.Finalizers = []string{snapshotFinalizer}
+	assert.NoError(t, c.Update(context.TODO(), planned))
+	assert.NoError(t, c.Delete(context.TODO(), planned))
+	_, err = r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, planned))
+	assert.Equal(t, []string{snapshotFinalizer}, planned.Finalizers)
+	if assert.Len(t, planned.Status.PlannedActions, 1) {
+		assert.True(t, strings.HasPrefix(planned.Status.PlannedActions[0], "Snapshot-JsonServer: "))
+	}
+	err = c.Get(context.TODO(), client.ObjectKey{Namespace: "team-a", Name: "app-planned" + snapshotConfigMapNameSuffix}, &corevV1.ConfigMap{})
+	assert.True(t, k8errors.IsNotFound(err), "snapshot must not be saved in dry-run mode")
+}
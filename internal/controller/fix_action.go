@@ -5,6 +5,7 @@ import (
 	"fmt"
 	v1 "github.com/m-szalik/json-server-operator/api/v1"
 	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"strings"
@@ -76,7 +77,77 @@ func UpdateResourceFixAction(jsonServer *v1.JsonServer, resource client.Object,
 	}
 }
 
+// adoptResourceFixAction takes over an existing resource without a controller, only the owner reference and labels of children
+// are added by a patch, so the resource is kept as it is. It is updated to the desired state in the next reconciliation.
+type adoptResourceFixAction struct {
+	baseFixAction
+	resource client.Object
+	labels   map[string]string
+	reason   string
+}
+
+func (a *adoptResourceFixAction) Reason() string {
+	return fmt.Sprintf("Adopt-%s", objectType(a.resource))
+}
+
+func (a *adoptResourceFixAction) Fix(ctx context.Context, r *JsonServerReconciler) error {
+	patch := client.MergeFrom(a.resource.DeepCopyObject().(client.Object))
+	if err := setControllerReference(a.JsonServer, a.resource, r); err != nil {
+		return err
+	}
+	labels := a.resource.GetLabels()
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	for key, val := range a.labels {
+		labels[key] = val
+	}
+	a.resource.SetLabels(labels)
+	return r.Patch(ctx, a.resource, patch)
+}
+
+func (a *adoptResourceFixAction) String() string {
+	return fmt.Sprintf("%s %s was not owned - %s", objectType(a.resource), client.ObjectKeyFromObject(a.resource), a.reason)
+}
+
+// AdoptResourceFixAction adopts current, an existing resource, adding labels of the desired child.
+func AdoptResourceFixAction(jsonServer *v1.JsonServer, current client.Object, labels map[string]string, reason string) FixAction {
+	return &adoptResourceFixAction{
+		baseFixAction: baseFixAction{jsonServer},
+		resource:      current,
+		labels:        labels,
+		reason:        reason,
+	}
+}
+
+type deleteResourceFixAction struct {
+	baseFixAction
+	resource client.Object
+}
+
+func (d *deleteResourceFixAction) Reason() string {
+	return fmt.Sprintf("Delete-%s", objectType(d.resource))
+}
+
+func (d *deleteResourceFixAction) Fix(ctx context.Context, r *JsonServerReconciler) error {
+	return client.IgnoreNotFound(r.Delete(ctx, d.resource))
+}
+
+func (d *deleteResourceFixAction) String() string {
+	return fmt.Sprintf("%s %s is no longer required", objectType(d.resource), client.ObjectKeyFromObject(d.resource))
+}
+
+func DeleteResourceFixAction(jsonServer *v1.JsonServer, resource client.Object) FixAction {
+	return &deleteResourceFixAction{
+		baseFixAction: baseFixAction{jsonServer},
+		resource:      resource,
+	}
+}
+
 func objectType(o client.Object) string {
+	if u, ok := o.(*unstructured.Unstructured); ok {
+		return u.GetKind()
+	}
 	str := fmt.Sprintf("%T", o)
 	parts := strings.Split(str, ".")
 	return parts[len(parts)-1]
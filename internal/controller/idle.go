@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	corevV1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strconv"
+	"time"
+)
+
+// activatorSliceManagedBy marks EndpointSlices routing requests of idle JsonServers to the activator
+const activatorSliceManagedBy = "jsonserver.example.com"
+
+// ActivatorService is the Service of the activator in the namespace of the operator, JsonServers are not scaled to zero if it is empty.
+var ActivatorService = ""
+
+// requestTrackingRequired reports if the last request time of jsonServer is tracked.
+func requestTrackingRequired(jsonServer *examplecomv1.JsonServer) bool {
+	return jsonServer.Spec.IdleTTLMinutes != nil || jsonServer.Spec.Idle != nil
+}
+
+// scaleToZeroTime returns when jsonServer is scaled to zero if it receives no more requests, nil if it is not scaled down.
+func scaleToZeroTime(jsonServer *examplecomv1.JsonServer) *time.Time {
+	idle := jsonServer.Spec.Idle
+	lastRequest := jsonServer.Status.LastRequestTime
+	if idle == nil || ActivatorService == "" || lastRequest == nil || jsonServer.Spec.Replicas == nil || *jsonServer.Spec.Replicas == 0 {
+		return nil
+	}
+	t := lastRequest.Add(idle.IdleAfter.Duration)
+	return &t
+}
+
+// scaleToZeroRequired reports if jsonServer has not received requests for idle.idleAfter.
+// Running pods are required, so the last request time has been refreshed from them.
+func scaleToZeroRequired(jsonServer *examplecomv1.JsonServer, readyPods int) bool {
+	scaleToZeroAt := scaleToZeroTime(jsonServer)
+	return readyPods > 0 && scaleToZeroAt != nil && !time.Now().Before(*scaleToZeroAt)
+}
+
+// scaleToZero sets replicas of jsonServer to zero, the activator restores them from IdleReplicasAnnotation.
+func (r *JsonServerReconciler) scaleToZero(ctx context.Context, jsonServer *examplecomv1.JsonServer) error {
+	replicas := *jsonServer.Spec.Replicas
+	if jsonServer.Annotations == nil {
+		jsonServer.Annotations = map[string]string{}
+	}
+	jsonServer.Annotations[examplecomv1.IdleReplicasAnnotation] = strconv.Itoa(int(replicas))
+	zero := int32(0)
+	jsonServer.Spec.Replicas = &zero
+	if err := r.updateSpec(ctx, jsonServer); err != nil {
+		return errors.Wrapf(err, "cannot scale to zero")
+	}
+	r.Recorder.Event(jsonServer, "Normal", "ScaledToZero", fmt.Sprintf("no requests for %s, scaled from %d to zero replicas", jsonServer.Spec.Idle.IdleAfter.Duration, replicas))
+	return nil
+}
+
+// activatorFixActions routes requests of jsonServer to the activator with an EndpointSlice of its Service while no pod is ready.
+func (r *JsonServerReconciler) activatorFixActions(ctx context.Context, jsonServer *examplecomv1.JsonServer) ([]FixAction, error) {
+	current := &discoveryv1.EndpointSlice{}
+	// EndpointSlices are not cached, the operator reads only its own
+	err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: jsonServer.Namespace, Name: activatorSliceName(jsonServer)}, current)
+	if err != nil && !k8errors.IsNotFound(err) {
+		return nil, errors.Wrapf(err, "cannot get EndpointSlice of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	exists := err == nil
+	if !jsonServer.Status.Idle || ActivatorService == "" {
+		if exists && metav1.IsControlledBy(current, jsonServer) {
+			return []FixAction{DeleteResourceFixAction(jsonServer, current)}, nil
+		}
+		return nil, nil
+	}
+	desired, err := r.createActivatorEndpointSlice(ctx, jsonServer)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return []FixAction{CreateResourceFixAction(jsonServer, desired)}, nil
+	}
+	if diffs := findResourceDifferences(desired, current); len(diffs) > 0 {
+		recordDrift(jsonServer, objectType(desired), diffs)
+		keepImmutableFields(desired, current)
+		return []FixAction{UpdateResourceFixAction(jsonServer, desired, fmt.Sprintf("differences: %v", diffs))}, nil
+	}
+	return nil, nil
+}
+
+func activatorSliceName(jsonServer *examplecomv1.JsonServer) string {
+	return jsonServer.ChildName() + "-activator"
+}
+
+// createActivatorEndpointSlice returns an EndpointSlice of the JsonServer Service with ready endpoints of the activator Service.
+func (r *JsonServerReconciler) createActivatorEndpointSlice(ctx context.Context, jsonServer *examplecomv1.JsonServer) (*discoveryv1.EndpointSlice, error) {
+	endpoints := &corevV1.Endpoints{}
+	if err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: r.OperatorNamespace, Name: ActivatorService}, endpoints); err != nil {
+		return nil, errors.Wrapf(err, "cannot get endpoints of the activator %s/%s", r.OperatorNamespace, ActivatorService)
+	}
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            activatorSliceName(jsonServer),
+			Namespace:       jsonServer.Namespace,
+			OwnerReferences: createOwnerReferences(jsonServer, false),
+			Labels: map[string]string{
+				discoveryv1.LabelServiceName: jsonServer.ChildName(),
+				discoveryv1.LabelManagedBy:   activatorSliceManagedBy,
+			},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+	}
+	ready := true
+	for _, subset := range endpoints.Subsets {
+		if len(subset.Ports) == 0 {
+			continue
+		}
+		for _, address := range subset.Addresses {
+			if ip := net.ParseIP(address.IP); ip != nil && ip.To4() == nil {
+				slice.AddressType = discoveryv1.AddressTypeIPv6
+			}
+			slice.Endpoints = append(slice.Endpoints, discoveryv1.Endpoint{
+				Addresses:  []string{address.IP},
+				Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+			})
+		}
+		if len(slice.Ports) == 0 {
+			// the port is matched by name with the port of the JsonServer Service
+			name := "http"
+			activatorPort := subset.Ports[0]
+			slice.Ports = []discoveryv1.EndpointPort{{Name: &name, Protocol: &activatorPort.Protocol, Port: &activatorPort.Port}}
+		}
+	}
+	if len(slice.Endpoints) == 0 {
+		return nil, fmt.Errorf("activator %s/%s has no ready endpoints", r.OperatorNamespace, ActivatorService)
+	}
+	return slice, nil
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	corevV1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+func newIdleTestJsonServer(replicas int32, lastRequest time.Time) *examplecomv1.JsonServer {
+	last := metav1.NewTime(lastRequest)
+	return &examplecomv1.JsonServer{
+		TypeMeta:   metav1.TypeMeta{APIVersion: examplecomv1.GroupVersion.String(), Kind: "JsonServer"},
+		ObjectMeta: metav1.ObjectMeta{Name: "app-one", Namespace: "team-a", UID: "uid"},
+		Spec: examplecomv1.JsonServerSpec{
+			Replicas: &replicas,
+			Idle:     &examplecomv1.IdleSpec{IdleAfter: metav1.Duration{Duration: 30 * time.Minute}},
+		},
+		Status: examplecomv1.JsonServerStatus{LastRequestTime: &last},
+	}
+}
+
+func Test_scaleToZeroRequired(t *testing.T) {
+	defer func() { ActivatorService = "" }()
+	ActivatorService = "activator"
+	idle := time.Now().Add(-time.Hour)
+	active := time.Now().Add(-time.Minute)
+	notIdle := newIdleTestJsonServer(2, idle)
+	notIdle.Spec.Idle = nil
+	tests := []struct {
+		name       string
+		jsonServer *examplecomv1.JsonServer
+		readyPods  int
+		want       bool
+	}{
+		{name: "idle", jsonServer: newIdleTestJsonServer(2, idle), readyPods: 2, want: true},
+		{name: "recent request", jsonServer: newIdleTestJsonServer(2, active), readyPods: 2},
+		{name: "already scaled to zero", jsonServer: newIdleTestJsonServer(0, idle)},
+		{name: "pods are starting", jsonServer: newIdleTestJsonServer(2, idle)},
+		{name: "idle not configured", jsonServer: notIdle, readyPods: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, scaleToZeroRequired(tt.jsonServer, tt.readyPods))
+		})
+	}
+}
+
+func Test_scaleToZeroTime(t *testing.T) {
+	defer func() { ActivatorService = "" }()
+	ActivatorService = "activator"
+	lastRequest := time.Now().Add(-time.Minute)
+	at := scaleToZeroTime(newIdleTestJsonServer(2, lastRequest))
+	if assert.NotNil(t, at) {
+		assert.Equal(t, lastRequest.Add(30*time.Minute), *at)
+	}
+	assert.Nil(t, scaleToZeroTime(newIdleTestJsonServer(0, lastRequest)), "already scaled to zero")
+	ActivatorService = ""
+	assert.Nil(t, scaleToZeroTime(newIdleTestJsonServer(2, lastRequest)), "without the activator")
+}
+
+func TestJsonServerReconciler_scaleToZero(t *testing.T) {
+	jsonServer := newIdleTestJsonServer(3, time.Now().Add(-time.Hour))
+	r := newTestReconciler(jsonServer)
+	c := r.Client
+
+	assert.NoError(t, r.scaleToZero(context.TODO(), jsonServer))
+	stored := &examplecomv1.JsonServer{}
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKeyFromObject(jsonServer), stored))
+	assert.Equal(t, int32(0), *stored.Spec.Replicas)
+	assert.Equal(t, "3", stored.Annotations[examplecomv1.IdleReplicasAnnotation])
+}
+
+func TestJsonServerReconciler_activatorFixActions(t *testing.T) {
+	defer func() { ActivatorService = "" }()
+	ActivatorService = "activator"
+	r := newTestReconciler(&corevV1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Name: "activator", Namespace: "operator"},
+		Subsets: []corevV1.EndpointSubset{{
+			Addresses: []corevV1.EndpointAddress{{IP: "10.1.0.5"}, {IP: "10.1.0.6"}},
+			Ports:     []corevV1.EndpointPort{{Name: "http", Port: 8070, Protocol: corevV1.ProtocolTCP}},
+		}},
+	})
+	r.OperatorNamespace = "operator"
+	c := r.Client
+	jsonServer := newIdleTestJsonServer(0, time.Now())
+
+	actions, err := r.activatorFixActions(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Empty(t, actions)
+
+	jsonServer.Status.Idle = true
+	actions, err = r.activatorFixActions(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Len(t, actions, 1)
+	assert.NoError(t, actions[0].Fix(context.TODO(), r))
+	slice := &discoveryv1.EndpointSlice{}
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Namespace: "team-a", Name: "app-one-activator"}, slice))
+	assert.Equal(t, "app-one", slice.Labels[discoveryv1.LabelServiceName])
+	assert.Len(t, slice.Endpoints, 2)
+	assert.Equal(t, int32(8070), *slice.Ports[0].Port)
+	assert.Equal(t, "http", *slice.Ports[0].Name)
+
+	actions, err = r.activatorFixActions(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Empty(t, actions)
+
+	jsonServer.Status.Idle = false
+	actions, err = r.activatorFixActions(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Len(t, actions, 1)
+	assert.Equal(t, "Delete-EndpointSlice", actions[0].Reason())
+}
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"strings"
+)
+
+// resolveClass returns JsonServerClass referenced by jsonServer, the default class if none is referenced or nil if there is no default class.
+func (r *JsonServerReconciler) resolveClass(ctx context.Context, jsonServer *examplecomv1.JsonServer) (*examplecomv1.JsonServerClass, error) {
+	if jsonServer.Spec.ClassName != nil && *jsonServer.Spec.ClassName != "" {
+		class := &examplecomv1.JsonServerClass{}
+		err := r.Get(ctx, client.ObjectKey{Name: *jsonServer.Spec.ClassName}, class)
+		if k8errors.IsNotFound(err) {
+			return nil, fmt.Errorf("JsonServerClass %s not found", *jsonServer.Spec.ClassName)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot get JsonServerClass %s", *jsonServer.Spec.ClassName)
+		}
+		return class, nil
+	}
+	classes := &examplecomv1.JsonServerClassList{}
+	if err := r.List(ctx, classes); err != nil {
+		return nil, errors.Wrap(err, "cannot list JsonServerClasses")
+	}
+	defaults := make([]*examplecomv1.JsonServerClass, 0)
+	for i := range classes.Items {
+		if classes.Items[i].IsDefault() {
+			defaults = append(defaults, &classes.Items[i])
+		}
+	}
+	switch len(defaults) {
+	case 0:
+		return nil, nil
+	case 1:
+		return defaults[0], nil
+	default:
+		names := make([]string, 0, len(defaults))
+		for _, c := range defaults {
+			names = append(names, c.Name)
+		}
+		return nil, fmt.Errorf("more than one default JsonServerClass: %s", strings.Join(names, ", "))
+	}
+}
+
+// mergeServerSettings returns defaults overridden by non-empty fields of override.
+func mergeServerSettings(override examplecomv1.ServerSettings, defaults examplecomv1.ServerSettings) examplecomv1.ServerSettings {
+	merged := *defaults.DeepCopy()
+	if override.Image != "" {
+		merged.Image = override.Image
+	}
+	if override.Resources != nil {
+		merged.Resources = override.Resources.DeepCopy()
+	}
+	if override.LivenessProbe != nil {
+		merged.LivenessProbe = override.LivenessProbe.DeepCopy()
+	}
+	if override.ReadinessProbe != nil {
+		merged.ReadinessProbe = override.ReadinessProbe.DeepCopy()
+	}
+	if override.Service != nil {
+		if merged.Service == nil {
+			merged.Service = &examplecomv1.ServiceSettings{}
+		}
+		if override.Service.Type != "" {
+			merged.Service.Type = override.Service.Type
+		}
+		for key, val := range override.Service.Annotations {
+			if merged.Service.Annotations == nil {
+				merged.Service.Annotations = map[string]string{}
+			}
+			merged.Service.Annotations[key] = val
+		}
+	}
+	return merged
+}
+
+// jsonServersForClass maps a JsonServerClass to JsonServers that may use it.
+// JsonServers without className are always included, as the class may have just become (or stopped being) the default one.
+func (r *JsonServerReconciler) jsonServersForClass(ctx context.Context, class client.Object) []reconcile.Request {
+	jsonServers := &examplecomv1.JsonServerList{}
+	if err := r.List(ctx, jsonServers); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list JsonServers for JsonServerClass "+class.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0)
+	for _, jsonServer := range jsonServers.Items {
+		className := jsonServer.Spec.ClassName
+		if className == nil || *className == "" || *className == class.GetName() {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&jsonServer)})
+		}
+	}
+	return requests
+}
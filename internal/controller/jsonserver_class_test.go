@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	corevV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func Test_mergeServerSettings(t *testing.T) {
+	defaults := examplecomv1.ServerSettings{
+		Image:   "class/image",
+		Service: &examplecomv1.ServiceSettings{Type: corevV1.ServiceTypeNodePort, Annotations: map[string]string{"a": "class", "b": "class"}},
+	}
+	override := examplecomv1.ServerSettings{
+		Service: &examplecomv1.ServiceSettings{Annotations: map[string]string{"b": "object"}},
+	}
+	merged := mergeServerSettings(override, defaults)
+	assert.Equal(t, "class/image", merged.Image)
+	assert.Equal(t, corevV1.ServiceTypeNodePort, merged.Service.Type)
+	assert.Equal(t, map[string]string{"a": "class", "b": "object"}, merged.Service.Annotations)
+	assert.Equal(t, "class", defaults.Service.Annotations["b"], "defaults must not be modified")
+
+	merged = mergeServerSettings(examplecomv1.ServerSettings{Image: "object/image"}, defaults)
+	assert.Equal(t, "object/image", merged.Image)
+}
+
+func TestJsonServerReconciler_resolveClass(t *testing.T) {
+	defaultClass := &examplecomv1.JsonServerClass{ObjectMeta: metav1.ObjectMeta{Name: "default", Annotations: map[string]string{examplecomv1.DefaultClassAnnotation: "true"}}}
+	otherClass := &examplecomv1.JsonServerClass{ObjectMeta: metav1.ObjectMeta{Name: "other"}}
+	className := func(name string) *string { return &name }
+	tests := []struct {
+		name      string
+		classes   []client.Object
+		className *string
+		want      string
+		wantErr   bool
+	}{
+		{name: "no classes", want: ""},
+		{name: "default class", classes: []client.Object{defaultClass, otherClass}, want: "default"},
+		{name: "referenced class", classes: []client.Object{defaultClass, otherClass}, className: className("other"), want: "other"},
+		{name: "missing class", classes: []client.Object{defaultClass}, className: className("other"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler(tt.classes...)
+			class, err := r.resolveClass(context.TODO(), &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{ClassName: tt.className}})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.want == "" {
+				assert.Nil(t, class)
+			} else {
+				assert.Equal(t, tt.want, class.Name)
+			}
+		})
+	}
+}
@@ -20,14 +20,27 @@ import (
 	"context"
 	"fmt"
 	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/m-szalik/json-server-operator/internal/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	v1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corevV1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 	"strings"
 	"time"
@@ -36,13 +49,33 @@ import (
 // JsonServerReconciler reconciles a JsonServer object
 type JsonServerReconciler struct {
 	client.Client
-	Scheme   *runtime.Scheme
-	Recorder record.EventRecorder
+	// APIReader reads objects that are not cached, like pods
+	APIReader client.Reader
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
+	// SidecarImage is the image of the sidecar container, DefaultSidecarImage if empty
+	SidecarImage string
+	// OperatorNamespace is allowed to call the sidecar admin port when JsonServer has a NetworkPolicy
+	OperatorNamespace string
+
+	restMapper            meta.RESTMapper
+	certManagerDetectedAt time.Time
 }
 
 //+kubebuilder:rbac:groups=example.com,resources=jsonservers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=example.com,resources=jsonservers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=example.com,resources=jsonservers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=example.com,resources=jsonservers/scale,verbs=get;update;patch
+//+kubebuilder:rbac:groups=example.com,resources=jsonserverclasses,verbs=get;list;watch
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups="",resources=pods,verbs=get;list
+//+kubebuilder:rbac:groups="",resources=endpoints,verbs=get
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;create;delete
+//+kubebuilder:rbac:groups=discovery.k8s.io,resources=endpointslices,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cert-manager.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -53,48 +86,149 @@ type JsonServerReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.15.0/pkg/reconcile
 func (r *JsonServerReconciler) Reconcile(ctx context.Context, req ctrl.Request) (rr ctrl.Result, rErr error) {
+	ctx, span := tracer.Start(ctx, "JsonServerReconciler.Reconcile", trace.WithAttributes(jsonServerAttributes(req.Namespace, req.Name)...))
+	// registered first, so it ends after the status is updated
+	defer func() { tracing.End(span, rErr) }()
 	logger := log.FromContext(ctx)
 	jsonServerResource := &examplecomv1.JsonServer{}
 	err := r.Get(ctx, req.NamespacedName, jsonServerResource)
 	if k8errors.IsNotFound(err) {
 		logger.Info("resource " + req.Namespace + "@" + req.Name + " deleted, sub resources will be removed automatically")
+		deleteMetrics(req.Namespace, req.Name)
 		return ctrl.Result{}, nil
 	}
 	if err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "cannot get resource %s", req)
+		return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryGet, errors.Wrapf(err, "cannot get resource %s", req))
+	}
+	// changes are planned again on every reconciliation, dry-run is checked before anything is written
+	dryRun := dryRunEnabled(jsonServerResource)
+	if !jsonServerResource.DeletionTimestamp.IsZero() {
+		if dryRun {
+			return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategorySnapshot, r.planFinalize(ctx, jsonServerResource))
+		}
+		if err := r.finalize(ctx, jsonServerResource); err != nil {
+			r.Recorder.Event(jsonServerResource, "Warning", "SnapshotFailed", err.Error())
+			return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategorySnapshot, err)
+		}
+		return ctrl.Result{}, nil
+	}
+	previousPlan := jsonServerResource.Status.PlannedActions
+	jsonServerResource.Status.PlannedActions = nil
+	if _, err := r.syncSnapshotFinalizer(ctx, jsonServerResource, dryRun); err != nil {
+		return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategorySnapshot, err)
+	}
+	// children are not changed while paused, all differences are repaired when resumed
+	paused := pausedEnabled(jsonServerResource)
+	r.emitPauseEvents(jsonServerResource)
+	lastRequestErr := r.refreshLastRequestTime(ctx, jsonServerResource)
+	if lastRequestErr != nil {
+		logger.Error(lastRequestErr, "cannot refresh last request time")
+	}
+	if expiration := expirationTime(jsonServerResource); expiration != nil && !time.Now().Before(*expiration) {
+		if !dryRun && !paused {
+			return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryExpiry, r.expire(ctx, jsonServerResource, *expiration))
+		}
+		planAction(jsonServerResource, "Delete-JsonServer", fmt.Sprintf("TTL expired at %s", expiration.UTC().Format(time.RFC3339)))
+	}
+	readyPods, err := r.getRunningPods(ctx, jsonServerResource)
+	if err != nil && !k8errors.IsNotFound(err) {
+		return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryGet, errors.Wrapf(err, "cannot check running pods"))
+	}
+	// due schedules are applied when resumed
+	if !paused {
+		if err := r.applySchedules(ctx, jsonServerResource, dryRun); err != nil {
+			return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryScaling, err)
+		}
+	}
+	// requests are held by the activator until a pod is ready
+	jsonServerResource.Status.Idle = jsonServerResource.Spec.Idle != nil && readyPods == 0
+	// an outdated last request time must not scale down a JsonServer with requests
+	if lastRequestErr == nil && !paused && scaleToZeroRequired(jsonServerResource, readyPods) {
+		if dryRun {
+			planAction(jsonServerResource, "ScaleToZero-JsonServer", fmt.Sprintf("no requests for %s", jsonServerResource.Spec.Idle.IdleAfter.Duration))
+		} else if err := r.scaleToZero(ctx, jsonServerResource); err != nil {
+			return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryScaling, err)
+		}
+	}
+	// children are renamed when resumed, until then the rename is planned
+	childName := jsonServerResource.Status.ChildName
+	if err := r.renameChildren(ctx, jsonServerResource, dryRun || paused); err != nil {
+		return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryValidation, err)
+	}
+	desiredJsonServer, desiredErr := r.desiredJsonServer(ctx, jsonServerResource)
+	if dryRun || paused {
+		// children are planned with the new name, the name is kept in status only when renamed
+		jsonServerResource.Status.ChildName = childName
+	}
+	fixActions, conflicts, criticalErrors, err := r.validateResources(ctx, desiredJsonServer)
+	if err == nil {
+		setConflictCondition(jsonServerResource, conflicts)
+		criticalErrors = append(criticalErrors, conflicts...)
 	}
-	fixActions, criticalErrors, err := r.validateResources(ctx, jsonServerResource)
 	defer func() {
 		if rErr != nil {
 			criticalErrors = append(criticalErrors, rErr.Error())
 		}
-		refreshRequested, err := r.updateStatus(ctx, jsonServerResource, criticalErrors, len(fixActions) > 0)
+		if dryRun {
+			r.emitPlanEvents(jsonServerResource, previousPlan)
+		}
+		refreshRequested, err := r.updateStatus(ctx, jsonServerResource, criticalErrors, len(fixActions) > 0 && !dryRun && !paused)
 		if err != nil {
 			rr = ctrl.Result{Requeue: true}
-			rErr = errors.Wrapf(err, "cannot update status")
+			rErr = recordReconcileError(req.Namespace, req.Name, errorCategoryStatus, errors.Wrapf(err, "cannot update status"))
 		} else {
 			if refreshRequested {
 				rr = ctrl.Result{RequeueAfter: 15 * time.Second}
 			}
+			// reconcile again when the TTL expires, status.expiresIn changes or replicas are scheduled to change,
+			// an expired JsonServer is only kept in dry-run mode
+			if expiration := expirationTime(jsonServerResource); expiration != nil && time.Now().Before(*expiration) {
+				_, changesAt := expiresIn(*expiration, time.Now())
+				rr = requeueNoLaterThan(rr, changesAt)
+			}
+			if next := jsonServerResource.Status.NextScheduledScaling; next != nil {
+				rr = requeueNoLaterThan(rr, next.Time.Time)
+			}
+			// the last request time is refreshed when idle.idleAfter elapses, pods are scaled to zero if there were no requests
+			if scaleToZeroAt := scaleToZeroTime(jsonServerResource); scaleToZeroAt != nil && time.Now().Before(*scaleToZeroAt) {
+				rr = requeueNoLaterThan(rr, *scaleToZeroAt)
+			}
 		}
 	}()
 	if err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "cannot validate current status")
+		return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryValidation, errors.Wrapf(err, "cannot validate current status"))
 	}
-	err = validateJson(jsonServerResource.Spec.JsonConfig) // An extra check. This json is validated also via webHook
+	if desiredErr != nil {
+		fixActions = nil
+		return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryConfig, desiredErr)
+	}
+	err = validateJson(desiredJsonServer.Spec.JsonConfig) // An extra check. This json is validated also via webHook
 	if err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "not valid jsonConfig")
+		return ctrl.Result{}, recordReconcileError(req.Namespace, req.Name, errorCategoryConfig, errors.Wrapf(err, "not valid jsonConfig"))
+	}
+	recordJsonConfig(jsonServerResource, desiredJsonServer.Spec.JsonConfig)
+	if paused {
+		logger.Info(fmt.Sprintf("reconciliation of %s@%s paused, %d fix actions skipped", req.Namespace, req.Name, len(fixActions)))
+		return ctrl.Result{}, nil
+	}
+	if dryRun {
+		for _, fixAction := range fixActions {
+			planAction(jsonServerResource, fixAction.Reason(), fixAction.String())
+		}
+		return ctrl.Result{}, nil
 	}
 	// apply fix actions to bring current state to desired state.
 	for _, fixAction := range fixActions {
-		err = fixAction.Fix(ctx, r)
+		err = r.fix(ctx, fixAction)
 		if err != nil {
 			logger.Error(err, "Action for: "+fixAction.String()+" error "+err.Error())
 			criticalErrors = append(criticalErrors, fmt.Sprintf("internal - %s: %s", fixAction.String(), err.Error()))
+			_ = recordReconcileError(req.Namespace, req.Name, errorCategoryFixAction, err)
 		} else {
 			logger.Info("Action for: " + fixAction.String() + " scheduled")
 		}
 		r.emmitEvent(jsonServerResource, fixAction, err)
+		recordFixAction(jsonServerResource, fixAction, err)
 	}
 	if len(fixActions) > 0 {
 		// actions have been taken, check the status again in a few seconds
@@ -106,35 +240,121 @@ func (r *JsonServerReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *JsonServerReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr)
+	r.restMapper = mgr.GetRESTMapper()
+	r.certManagerDetectedAt = time.Now()
+	certManagerInstalled = detectCertManager(mgr.GetRESTMapper())
+	if certManagerInstalled {
+		builder = builder.Owns(newCertificate())
+	} else {
+		mgr.GetLogger().Info("cert-manager is not installed, Certificates will not be created for JsonServers")
+	}
+	serviceMonitorInstalled = detectServiceMonitor(mgr.GetRESTMapper())
+	if serviceMonitorInstalled {
+		builder = builder.Owns(newServiceMonitor())
+	} else {
+		mgr.GetLogger().Info("Prometheus Operator is not installed, ServiceMonitors will not be created for JsonServers")
+	}
+	return builder.
 		For(&examplecomv1.JsonServer{}).
 		Owns(&v1.Deployment{}).
 		Owns(&corevV1.ConfigMap{}).
 		Owns(&corevV1.Service{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Watches(&examplecomv1.JsonServerClass{}, handler.EnqueueRequestsFromMapFunc(r.jsonServersForClass)).
 		Complete(r)
 }
 
-func (r *JsonServerReconciler) validateResources(ctx context.Context, jsonServer *examplecomv1.JsonServer) ([]FixAction, []string, error) {
-	fixActions := make([]FixAction, 0)
-	criticalErrors := make([]string, 0)
-	resourceObjectsFunc := []func(*examplecomv1.JsonServer) client.Object{
-		createJsonServerConfigMapResource,
-		createJsonServerDeploymentResource,
-		createJsonServerServiceResource,
+// desiredJsonServer returns a copy of jsonServer that children are created from,
+// with jsonConfig rendered and settings of JsonServerClass merged in.
+func (r *JsonServerReconciler) desiredJsonServer(ctx context.Context, jsonServer *examplecomv1.JsonServer) (*examplecomv1.JsonServer, error) {
+	desired := jsonServer.DeepCopy()
+	jsonConfig, err := jsonServer.RenderedJsonConfig()
+	if err != nil {
+		return desired, errors.Wrapf(err, "cannot render jsonConfig template")
+	}
+	desired.Spec.JsonConfig = jsonConfig
+	class, err := r.resolveClass(ctx, jsonServer)
+	if err != nil {
+		return desired, err
+	}
+	if class != nil {
+		desired.Spec.ServerSettings = mergeServerSettings(jsonServer.Spec.ServerSettings, class.Spec.ServerSettings)
+	}
+	return desired, nil
+}
+
+// validateResources returns actions bringing children to the desired state and conflicts with existing resources
+// which are not children, no actions are taken for them.
+func (r *JsonServerReconciler) validateResources(ctx context.Context, jsonServer *examplecomv1.JsonServer) (fixActions []FixAction, conflicts []string, criticalErrors []string, err error) {
+	ctx, span := tracer.Start(ctx, "JsonServerReconciler.validateResources", trace.WithAttributes(jsonServerAttributes(jsonServer.Namespace, jsonServer.Name)...))
+	defer func() {
+		span.SetAttributes(attribute.Int("jsonserver.fix_actions", len(fixActions)), attribute.StringSlice("jsonserver.conflicts", conflicts),
+			attribute.StringSlice("jsonserver.critical_errors", criticalErrors))
+		tracing.End(span, err)
+	}()
+	fixActions = make([]FixAction, 0)
+	conflicts = make([]string, 0)
+	criticalErrors = make([]string, 0)
+	r.redetectCertManager()
+	tlsUnavailable := certificateUnavailable(jsonServer)
+	if tlsUnavailable != "" {
+		criticalErrors = append(criticalErrors, tlsUnavailable)
 	}
-	for _, resourceObjectFactoryFunc := range resourceObjectsFunc {
+	for _, child := range r.childResources() {
+		if child.available != nil && !child.available() {
+			continue
+		}
+		resourceObjectFactoryFunc := child.create
 		to := resourceObjectFactoryFunc(jsonServer)
-		err := r.Get(ctx, client.ObjectKeyFromObject(jsonServer), to)
+		if _, ok := to.(*v1.Deployment); ok && tlsUnavailable != "" {
+			continue
+		}
+		err := r.Get(ctx, childKey(jsonServer), to)
 		desired := resourceObjectFactoryFunc(jsonServer)
+		if child.required != nil && !child.required(jsonServer) {
+			if err == nil && metav1.IsControlledBy(to, jsonServer) {
+				fixActions = append(fixActions, DeleteResourceFixAction(jsonServer, to))
+			}
+			continue
+		}
 		if k8errors.IsNotFound(err) {
 			fixActions = append(fixActions, CreateResourceFixAction(jsonServer, desired))
+		} else if err == nil && !metav1.IsControlledBy(to, jsonServer) {
+			if !adoptable(jsonServer, to) {
+				conflicts = append(conflicts, conflictMessage(to))
+				continue
+			}
+			if conflict := adoptionConflict(desired, to); conflict != "" {
+				conflicts = append(conflicts, conflict)
+				continue
+			}
+			diffs := findResourceDifferences(desired, to)
+			fixActions = append(fixActions, AdoptResourceFixAction(jsonServer, to, desired.GetLabels(), fmt.Sprintf("differences: [%s]", strings.Join(diffs, ", "))))
 		} else {
 			if diffs := findResourceDifferences(desired, to); len(diffs) > 0 {
+				recordDrift(jsonServer, objectType(desired), diffs)
+				keepImmutableFields(desired, to)
 				fixActions = append(fixActions, UpdateResourceFixAction(jsonServer, desired, fmt.Sprintf("differences: [%s]", strings.Join(diffs, ", "))))
 			}
 		}
 	}
-	return fixActions, criticalErrors, nil
+	activatorActions, err := r.activatorFixActions(ctx, jsonServer)
+	if err != nil {
+		criticalErrors = append(criticalErrors, err.Error())
+	}
+	fixActions = append(fixActions, activatorActions...)
+	adminActions, conflict, err := r.adminSecretFixActions(ctx, jsonServer)
+	if err != nil {
+		criticalErrors = append(criticalErrors, err.Error())
+	}
+	if conflict != "" {
+		conflicts = append(conflicts, conflict)
+	}
+	fixActions = append(fixActions, adminActions...)
+	return fixActions, conflicts, criticalErrors, nil
 }
 
 func (r *JsonServerReconciler) emmitEvent(jsonServer *examplecomv1.JsonServer, action FixAction, err error) {
@@ -149,7 +369,7 @@ func (r *JsonServerReconciler) emmitEvent(jsonServer *examplecomv1.JsonServer, a
 
 func (r *JsonServerReconciler) getRunningPods(ctx context.Context, jsonServer *examplecomv1.JsonServer) (int, error) {
 	deployment := &v1.Deployment{}
-	err := r.Get(ctx, client.ObjectKeyFromObject(jsonServer), deployment)
+	err := r.Get(ctx, childKey(jsonServer), deployment)
 	if err != nil {
 		return 0, err
 	}
@@ -179,11 +399,223 @@ func findResourceDifferences(desired client.Object, current client.Object) []str
 		if do.Spec.Replicas != nil && *co.Spec.Replicas != *do.Spec.Replicas {
 			diffs = append(diffs, "replicas")
 		}
+		if len(do.Spec.Template.Spec.Containers) != len(co.Spec.Template.Spec.Containers) {
+			diffs = append(diffs, "containers")
+		}
+		for _, name := range []string{containerName, sidecarContainerName} {
+			diffs = append(diffs, findContainerDifferences(findContainer(do.Spec.Template.Spec.Containers, name), findContainer(co.Spec.Template.Spec.Containers, name))...)
+		}
+		for key, doVal := range do.Spec.Template.Annotations {
+			if coVal, ok := co.Spec.Template.Annotations[key]; !ok || coVal != doVal {
+				diffs = append(diffs, "pod annotation "+key)
+			}
+		}
+	case *autoscalingv2.HorizontalPodAutoscaler:
+		co := current.(*autoscalingv2.HorizontalPodAutoscaler)
+		if do.Spec.ScaleTargetRef != co.Spec.ScaleTargetRef {
+			diffs = append(diffs, "scaleTargetRef")
+		}
+		if !equality.Semantic.DeepEqual(do.Spec.MinReplicas, co.Spec.MinReplicas) {
+			diffs = append(diffs, "minReplicas")
+		}
+		if do.Spec.MaxReplicas != co.Spec.MaxReplicas {
+			diffs = append(diffs, "maxReplicas")
+		}
+		if !equality.Semantic.DeepEqual(do.Spec.Metrics, co.Spec.Metrics) {
+			diffs = append(diffs, "metrics")
+		}
+	case *policyv1.PodDisruptionBudget:
+		co := current.(*policyv1.PodDisruptionBudget)
+		if !equality.Semantic.DeepEqual(do.Spec.MinAvailable, co.Spec.MinAvailable) {
+			diffs = append(diffs, "minAvailable")
+		}
+		if !equality.Semantic.DeepEqual(do.Spec.MaxUnavailable, co.Spec.MaxUnavailable) {
+			diffs = append(diffs, "maxUnavailable")
+		}
+		if !equality.Semantic.DeepEqual(do.Spec.Selector, co.Spec.Selector) {
+			diffs = append(diffs, "selector")
+		}
+	case *networkingv1.NetworkPolicy:
+		co := current.(*networkingv1.NetworkPolicy)
+		if !equality.Semantic.DeepEqual(do.Spec.PodSelector, co.Spec.PodSelector) {
+			diffs = append(diffs, "podSelector")
+		}
+		if !equality.Semantic.DeepEqual(do.Spec.Ingress, co.Spec.Ingress) {
+			diffs = append(diffs, "ingress")
+		}
+	case *unstructured.Unstructured:
+		// only fields set by the operator are compared, others may be defaulted
+		doSpec, _, _ := unstructured.NestedMap(do.Object, "spec")
+		coSpec, _, _ := unstructured.NestedMap(current.(*unstructured.Unstructured).Object, "spec")
+		for key, doVal := range doSpec {
+			if !equality.Semantic.DeepEqual(doVal, coSpec[key]) {
+				diffs = append(diffs, key)
+			}
+		}
+		for key, doVal := range do.GetLabels() {
+			if coVal, ok := current.GetLabels()[key]; !ok || coVal != doVal {
+				diffs = append(diffs, "label "+key)
+			}
+		}
+	case *discoveryv1.EndpointSlice:
+		co := current.(*discoveryv1.EndpointSlice)
+		if do.AddressType != co.AddressType {
+			diffs = append(diffs, "addressType")
+		}
+		if !equality.Semantic.DeepEqual(do.Endpoints, co.Endpoints) {
+			diffs = append(diffs, "endpoints")
+		}
+		if !equality.Semantic.DeepEqual(do.Ports, co.Ports) {
+			diffs = append(diffs, "ports")
+		}
+	case *corevV1.Service:
+		co := current.(*corevV1.Service)
+		if do.Spec.Type != co.Spec.Type {
+			diffs = append(diffs, "type")
+		}
+		if servicePortNames(do) != servicePortNames(co) {
+			diffs = append(diffs, "ports")
+		}
+		for key, doVal := range do.Labels {
+			if coVal, ok := co.Labels[key]; !ok || coVal != doVal {
+				diffs = append(diffs, "label "+key)
+			}
+		}
+		for key, doVal := range do.Annotations {
+			if coVal, ok := co.Annotations[key]; !ok || coVal != doVal {
+				diffs = append(diffs, "annotation "+key)
+			}
+		}
+		// annotations set by others are kept, only the ones set by the operator are removed
+		for _, key := range strings.Split(co.Annotations[managedAnnotationsAnnotation], ",") {
+			if _, ok := do.Annotations[key]; key != "" && !ok {
+				diffs = append(diffs, "annotation "+key)
+			}
+		}
+	}
+	return diffs
+}
+
+func servicePortNames(service *corevV1.Service) string {
+	names := make([]string, 0, len(service.Spec.Ports))
+	for _, p := range service.Spec.Ports {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func findContainerDifferences(desired *corevV1.Container, current *corevV1.Container) []string {
+	diffs := make([]string, 0)
+	if desired == nil {
+		return diffs
+	}
+	if current == nil {
+		return append(diffs, "missing container "+desired.Name)
+	}
+	if desired.Image != current.Image {
+		diffs = append(diffs, desired.Name+" image")
+	}
+	if !equality.Semantic.DeepEqual(desired.Args, current.Args) {
+		diffs = append(diffs, desired.Name+" args")
+	}
+	if resourcesDiffer(desired.Resources, current.Resources) {
+		diffs = append(diffs, desired.Name+" resources")
+	}
+	if probeDiffers(desired.LivenessProbe, current.LivenessProbe) {
+		diffs = append(diffs, desired.Name+" livenessProbe")
+	}
+	if probeDiffers(desired.ReadinessProbe, current.ReadinessProbe) {
+		diffs = append(diffs, desired.Name+" readinessProbe")
 	}
 	return diffs
 }
 
-func (r *JsonServerReconciler) updateStatus(ctx context.Context, jsonServerResource *examplecomv1.JsonServer, criticalErrors []string, fixActionExecuted bool) (bool, error) {
+// resourcesDiffer compares quantities set in desired, requests are defaulted to limits by the API server
+// and LimitRanges may add others.
+func resourcesDiffer(desired corevV1.ResourceRequirements, current corevV1.ResourceRequirements) bool {
+	for name, quantity := range desired.Limits {
+		if c, ok := current.Limits[name]; !ok || quantity.Cmp(c) != 0 {
+			return true
+		}
+	}
+	for name, quantity := range desired.Requests {
+		if c, ok := current.Requests[name]; !ok || quantity.Cmp(c) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// probeDiffers compares fields set in desired, the others are defaulted by the API server.
+func probeDiffers(desired *corevV1.Probe, current *corevV1.Probe) bool {
+	if desired == nil || current == nil {
+		return desired != current
+	}
+	merged := desired.DeepCopy()
+	if merged.TimeoutSeconds == 0 {
+		merged.TimeoutSeconds = current.TimeoutSeconds
+	}
+	if merged.PeriodSeconds == 0 {
+		merged.PeriodSeconds = current.PeriodSeconds
+	}
+	if merged.SuccessThreshold == 0 {
+		merged.SuccessThreshold = current.SuccessThreshold
+	}
+	if merged.FailureThreshold == 0 {
+		merged.FailureThreshold = current.FailureThreshold
+	}
+	if merged.HTTPGet != nil && current.HTTPGet != nil && merged.HTTPGet.Scheme == "" {
+		merged.HTTPGet.Scheme = current.HTTPGet.Scheme
+	}
+	if merged.GRPC != nil && current.GRPC != nil && merged.GRPC.Service == nil {
+		merged.GRPC.Service = current.GRPC.Service
+	}
+	return !equality.Semantic.DeepEqual(merged, current)
+}
+
+func findContainer(containers []corevV1.Container, name string) *corevV1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// requeueNoLaterThan shortens RequeueAfter of result to reconcile right after t.
+func requeueNoLaterThan(result ctrl.Result, t time.Time) ctrl.Result {
+	if until := time.Until(t) + time.Second; result.RequeueAfter == 0 || until < result.RequeueAfter {
+		result.RequeueAfter = until
+	}
+	return result
+}
+
+// updateSpec updates jsonServer keeping its status, which is not stored by Update and updated at the end of reconciliation.
+func (r *JsonServerReconciler) updateSpec(ctx context.Context, jsonServer *examplecomv1.JsonServer) error {
+	status := jsonServer.Status.DeepCopy()
+	if err := r.Update(ctx, jsonServer); err != nil {
+		return errors.Wrapf(err, "cannot update %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	jsonServer.Status = *status
+	return nil
+}
+
+// fix executes fixAction in its own span.
+func (r *JsonServerReconciler) fix(ctx context.Context, fixAction FixAction) (err error) {
+	ctx, span := tracer.Start(ctx, "FixAction.Fix", trace.WithAttributes(
+		attribute.String("fixaction.reason", fixAction.Reason()),
+		attribute.String("fixaction.description", fixAction.String()),
+	))
+	defer func() { tracing.End(span, err) }()
+	return fixAction.Fix(ctx, r)
+}
+
+func (r *JsonServerReconciler) updateStatus(ctx context.Context, jsonServerResource *examplecomv1.JsonServer, criticalErrors []string, fixActionExecuted bool) (_ bool, rErr error) {
+	ctx, span := tracer.Start(ctx, "JsonServerReconciler.updateStatus", trace.WithAttributes(jsonServerAttributes(jsonServerResource.Namespace, jsonServerResource.Name)...))
+	defer func() {
+		span.SetAttributes(attribute.String("jsonserver.state", string(jsonServerResource.Status.SyncState)))
+		tracing.End(span, rErr)
+	}()
 	refreshRequired := false
 	logger := log.FromContext(ctx)
 	status := examplecomv1.JsonServerStatus{SyncState: examplecomv1.SyncStateSynced, SyncMessage: "Synced successfully!"}
@@ -193,6 +625,8 @@ func (r *JsonServerReconciler) updateStatus(ctx context.Context, jsonServerResou
 	if fixActionExecuted {
 		status = examplecomv1.JsonServerStatus{SyncState: examplecomv1.SyncStateNotSynced, SyncMessage: "Updating"}
 	}
+	// selector of the scale subresource, used by HorizontalPodAutoscaler to find pods
+	status.Selector = labels.SelectorFromSet(map[string]string{"app": jsonServerResource.ChildName()}).String()
 	if runningPods, err := r.getRunningPods(ctx, jsonServerResource); err != nil {
 		logger.Error(err, "cannot check running pods")
 	} else {
@@ -203,8 +637,58 @@ func (r *JsonServerReconciler) updateStatus(ctx context.Context, jsonServerResou
 		status.Replicas = int32(runningPods)
 		refreshRequired = true
 	}
+	if sidecarRequired(jsonServerResource) {
+		stats, err := r.collectSidecarStats(ctx, jsonServerResource)
+		for _, s := range stats {
+			status.UnauthenticatedRequests += s.UnauthenticatedRequests
+		}
+		if err != nil {
+			logger.Error(err, "cannot collect sidecar stats")
+			// pods which did not answer are not counted, the previous value is kept if it is higher
+			if previous := jsonServerResource.Status.UnauthenticatedRequests; previous > status.UnauthenticatedRequests {
+				status.UnauthenticatedRequests = previous
+			}
+		}
+	}
+	status.LastRequestTime = jsonServerResource.Status.LastRequestTime
+	status.Idle = jsonServerResource.Status.Idle
+	status.LastScheduledScaling = jsonServerResource.Status.LastScheduledScaling
+	status.NextScheduledScaling = jsonServerResource.Status.NextScheduledScaling
+	status.PlannedActions = jsonServerResource.Status.PlannedActions
+	status.ChildName = jsonServerResource.Status.ChildName
+	if len(status.PlannedActions) > 0 && status.SyncState == examplecomv1.SyncStateSynced {
+		status.SyncState = examplecomv1.SyncStateNotSynced
+		status.SyncMessage = fmt.Sprintf("Dry run, %d planned actions", len(status.PlannedActions))
+	}
+	if expiration := expirationTime(jsonServerResource); expiration != nil {
+		status.ExpirationTime = &metav1.Time{Time: *expiration}
+		status.ExpiresIn, _ = expiresIn(*expiration, time.Now())
+	}
+	status.Conditions = jsonServerResource.Status.Conditions
+	if condition, err := r.certificateCondition(ctx, jsonServerResource); err != nil {
+		logger.Error(err, "cannot check certificate")
+	} else if condition != nil {
+		meta.SetStatusCondition(&status.Conditions, *condition)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, examplecomv1.ConditionCertificateReady)
+	}
+	if condition := pausedCondition(jsonServerResource); condition != nil {
+		meta.SetStatusCondition(&status.Conditions, *condition)
+		if status.SyncState != examplecomv1.SyncStateError {
+			status.SyncState = examplecomv1.SyncStatePaused
+			status.SyncMessage = "Reconciliation paused"
+		}
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, examplecomv1.ConditionPaused)
+	}
+	if equality.Semantic.DeepEqual(jsonServerResource.Status, status) {
+		// an update would trigger another reconciliation without changing anything
+		recordStatusState(jsonServerResource)
+		return refreshRequired, nil
+	}
 	logger.Info("updating status of " + jsonServerResource.Namespace + "@" + jsonServerResource.Name)
 	jsonServerResource.Status = status
+	recordStatusState(jsonServerResource)
 	err := r.Status().Update(ctx, jsonServerResource)
 	if err != nil && !k8errors.IsNotFound(err) {
 		return true, errors.Wrapf(err, "cannot update status of %v", jsonServerResource)
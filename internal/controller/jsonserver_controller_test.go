@@ -5,6 +5,9 @@ import (
 	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
 	"github.com/stretchr/testify/assert"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
@@ -67,6 +70,24 @@ func Test_findResourceDifferences(t *testing.T) {
 				current: &v1.ConfigMap{Data: map[string]string{"k": "value"}},
 			}, want: []string{"data field k changed"},
 		},
+		{
+			args: args{
+				desired: &v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeNodePort}},
+				current: &v1.Service{Spec: v1.ServiceSpec{Type: v1.ServiceTypeClusterIP}},
+			}, want: []string{"type"},
+		},
+		{
+			args: args{
+				desired: &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1"}}},
+				current: &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1", "b": "2"}}},
+			}, want: []string{},
+		},
+		{
+			args: args{
+				desired: &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1", managedAnnotationsAnnotation: "a"}}},
+				current: &v1.Service{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{"a": "1", "b": "2", managedAnnotationsAnnotation: "a,b"}}},
+			}, want: []string{"annotation " + managedAnnotationsAnnotation, "annotation b"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(fmt.Sprintf("findResourceDifferences(%v vs %v)", tt.args.current, tt.args.desired), func(t *testing.T) {
@@ -74,3 +95,28 @@ func Test_findResourceDifferences(t *testing.T) {
 		})
 	}
 }
+
+func Test_findContainerDifferences_defaults(t *testing.T) {
+	desired := &v1.Container{
+		Name: "json-server",
+		Resources: v1.ResourceRequirements{
+			Limits: v1.ResourceList{v1.ResourceCPU: resource.MustParse("500m")},
+		},
+		LivenessProbe: &v1.Probe{
+			ProbeHandler:     v1.ProbeHandler{HTTPGet: &v1.HTTPGetAction{Path: "/", Port: intstr.FromInt(3000)}},
+			FailureThreshold: 5,
+		},
+	}
+	// defaulted by the API server
+	current := desired.DeepCopy()
+	current.Resources.Requests = v1.ResourceList{v1.ResourceCPU: resource.MustParse("0.5")}
+	current.LivenessProbe.HTTPGet.Scheme = v1.URISchemeHTTP
+	current.LivenessProbe.TimeoutSeconds = 1
+	current.LivenessProbe.PeriodSeconds = 10
+	current.LivenessProbe.SuccessThreshold = 1
+	assert.Empty(t, findContainerDifferences(desired, current))
+
+	current.LivenessProbe.FailureThreshold = 3
+	current.Resources.Limits[v1.ResourceCPU] = resource.MustParse("1")
+	assert.Equal(t, []string{"json-server resources", "json-server livenessProbe"}, findContainerDifferences(desired, current))
+}
@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// JsonServerQuotaReconciler reports usage of JsonServers in status of JsonServerQuotas, quotas are enforced by the webhook
+type JsonServerQuotaReconciler struct {
+	client.Client
+	// APIReader lists JsonServers, the cache may contain only some of them when a label selector is used
+	APIReader client.Reader
+	Scheme    *runtime.Scheme
+}
+
+//+kubebuilder:rbac:groups=example.com,resources=jsonserverquotas,verbs=get;list;watch
+//+kubebuilder:rbac:groups=example.com,resources=jsonserverquotas/status,verbs=get;update;patch
+
+func (r *JsonServerQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	quota := &examplecomv1.JsonServerQuota{}
+	err := r.Get(ctx, req.NamespacedName, quota)
+	if k8errors.IsNotFound(err) {
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "cannot get JsonServerQuota %s", req)
+	}
+	jsonServers := &examplecomv1.JsonServerList{}
+	if err := r.APIReader.List(ctx, jsonServers, client.InNamespace(req.Namespace)); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "cannot list JsonServers of namespace %s", req.Namespace)
+	}
+	used := examplecomv1.QuotaUsage(jsonServers.Items)
+	if used == quota.Status.Used {
+		return ctrl.Result{}, nil
+	}
+	log.FromContext(ctx).Info("updating usage of JsonServerQuota " + req.String())
+	quota.Status.Used = used
+	if err := r.Status().Update(ctx, quota); err != nil && !k8errors.IsNotFound(err) {
+		return ctrl.Result{}, errors.Wrapf(err, "cannot update status of JsonServerQuota %s", req)
+	}
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *JsonServerQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&examplecomv1.JsonServerQuota{}).
+		Watches(&examplecomv1.JsonServer{}, handler.EnqueueRequestsFromMapFunc(r.quotasForJsonServer)).
+		Complete(r)
+}
+
+// quotasForJsonServer maps a JsonServer to quotas of its namespace.
+func (r *JsonServerQuotaReconciler) quotasForJsonServer(ctx context.Context, jsonServer client.Object) []reconcile.Request {
+	quotas := &examplecomv1.JsonServerQuotaList{}
+	if err := r.List(ctx, quotas, client.InNamespace(jsonServer.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "cannot list JsonServerQuotas of namespace "+jsonServer.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(quotas.Items))
+	for i := range quotas.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&quotas.Items[i])})
+	}
+	return requests
+}
@@ -0,0 +1,29 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestJsonServerQuotaReconciler_Reconcile(t *testing.T) {
+	quota := &examplecomv1.JsonServerQuota{ObjectMeta: metav1.ObjectMeta{Name: "quota", Namespace: "team-a"}}
+	c := newTestReconciler(
+		quota,
+		&examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-one", Namespace: "team-a"}, Spec: examplecomv1.JsonServerSpec{Replicas: int32Ptr(3), JsonConfig: "{}"}},
+		&examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-two", Namespace: "team-a"}, Spec: examplecomv1.JsonServerSpec{
+			Replicas: int32Ptr(1), JsonConfig: `{"a":1}`, Autoscaling: &examplecomv1.AutoscalingSpec{MaxReplicas: 4},
+		}},
+		&examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-three", Namespace: "team-b"}, Spec: examplecomv1.JsonServerSpec{Replicas: int32Ptr(1)}},
+	).Client
+	r := &JsonServerQuotaReconciler{Client: c, APIReader: c, Scheme: c.Scheme()}
+	_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(quota)})
+	assert.NoError(t, err)
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKeyFromObject(quota), quota))
+	assert.Equal(t, examplecomv1.JsonServerQuotaUsage{JsonServers: 2, TotalReplicas: 7, JsonConfigBytes: 9}, quota.Status.Used)
+	assert.Len(t, r.quotasForJsonServer(context.TODO(), &examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a"}}), 1)
+}
@@ -0,0 +1,117 @@
+package controller
+
+import (
+	"encoding/json"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Categories of reconcile errors
+const (
+	errorCategoryGet        = "get"
+	errorCategorySnapshot   = "snapshot"
+	errorCategoryExpiry     = "expiry"
+	errorCategoryScaling    = "scaling"
+	errorCategoryValidation = "validation"
+	errorCategoryConfig     = "config"
+	errorCategoryFixAction  = "fix-action"
+	errorCategoryStatus     = "status"
+)
+
+var (
+	fixActionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jsonserver_fix_actions_total",
+		Help: "Fix actions executed on resources of a JsonServer by reason (like Create-Deployment) and result",
+	}, []string{"namespace", "jsonserver", "reason", "result"})
+	driftDetectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jsonserver_drift_detections_total",
+		Help: "Differences between desired and current resources of a JsonServer by resource type and field",
+	}, []string{"namespace", "jsonserver", "resource", "field"})
+	statusState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jsonserver_status_state",
+		Help: "State of a JsonServer, 1 for the current state and 0 for others",
+	}, []string{"namespace", "jsonserver", "state"})
+	jsonConfigBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jsonserver_json_config_bytes",
+		Help: "Size of the rendered jsonConfig of a JsonServer",
+	}, []string{"namespace", "jsonserver"})
+	jsonConfigCollections = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jsonserver_json_config_collections",
+		Help: "Number of collections (top-level keys) in the rendered jsonConfig of a JsonServer",
+	}, []string{"namespace", "jsonserver"})
+	jsonConfigCollectionItems = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "jsonserver_json_config_collection_items",
+		Help: "Number of items of an array collection in the rendered jsonConfig of a JsonServer",
+	}, []string{"namespace", "jsonserver", "collection"})
+	reconcileErrorsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "jsonserver_reconcile_errors_total",
+		Help: "Errors of reconciliation of a JsonServer by category",
+	}, []string{"namespace", "jsonserver", "category"})
+)
+
+var syncStates = []examplecomv1.SyncState{examplecomv1.SyncStateSynced, examplecomv1.SyncStateNotSynced, examplecomv1.SyncStateError, examplecomv1.SyncStatePaused}
+
+func init() {
+	metrics.Registry.MustRegister(fixActionsTotal, driftDetectionsTotal, statusState, jsonConfigBytes, jsonConfigCollections,
+		jsonConfigCollectionItems, reconcileErrorsTotal)
+}
+
+func recordFixAction(jsonServer *examplecomv1.JsonServer, action FixAction, err error) {
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	fixActionsTotal.WithLabelValues(jsonServer.Namespace, jsonServer.Name, action.Reason(), result).Inc()
+}
+
+func recordDrift(jsonServer *examplecomv1.JsonServer, resourceType string, diffs []string) {
+	for _, field := range diffs {
+		driftDetectionsTotal.WithLabelValues(jsonServer.Namespace, jsonServer.Name, resourceType, field).Inc()
+	}
+}
+
+// recordReconcileError counts err in category and returns it, nil errors are not counted.
+func recordReconcileError(namespace, name, category string, err error) error {
+	if err != nil {
+		reconcileErrorsTotal.WithLabelValues(namespace, name, category).Inc()
+	}
+	return err
+}
+
+func recordStatusState(jsonServer *examplecomv1.JsonServer) {
+	for _, state := range syncStates {
+		value := 0.0
+		if jsonServer.Status.SyncState == state {
+			value = 1
+		}
+		statusState.WithLabelValues(jsonServer.Namespace, jsonServer.Name, string(state)).Set(value)
+	}
+}
+
+// recordJsonConfig records size and collections of a rendered jsonConfig.
+func recordJsonConfig(jsonServer *examplecomv1.JsonServer, jsonConfig string) {
+	jsonConfigBytes.WithLabelValues(jsonServer.Namespace, jsonServer.Name).Set(float64(len(jsonConfig)))
+	collections := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(jsonConfig), &collections); err != nil {
+		return
+	}
+	jsonConfigCollections.WithLabelValues(jsonServer.Namespace, jsonServer.Name).Set(float64(len(collections)))
+	// removed collections must not be reported
+	jsonConfigCollectionItems.DeletePartialMatch(prometheus.Labels{"namespace": jsonServer.Namespace, "jsonserver": jsonServer.Name})
+	for name, raw := range collections {
+		items := make([]json.RawMessage, 0)
+		if err := json.Unmarshal(raw, &items); err == nil {
+			jsonConfigCollectionItems.WithLabelValues(jsonServer.Namespace, jsonServer.Name, name).Set(float64(len(items)))
+		}
+	}
+}
+
+// deleteMetrics removes metrics of a deleted JsonServer.
+func deleteMetrics(namespace, name string) {
+	labels := prometheus.Labels{"namespace": namespace, "jsonserver": name}
+	for _, vec := range []*prometheus.MetricVec{fixActionsTotal.MetricVec, driftDetectionsTotal.MetricVec, statusState.MetricVec,
+		jsonConfigBytes.MetricVec, jsonConfigCollections.MetricVec, jsonConfigCollectionItems.MetricVec, reconcileErrorsTotal.MetricVec} {
+		vec.DeletePartialMatch(labels)
+	}
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"testing"
+)
+
+func Test_recordJsonConfig(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-metrics", Namespace: "team-a"}}
+	defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+
+	jsonConfig := `{"people":[{"id":1},{"id":2}],"settings":{"a":1}}`
+	recordJsonConfig(jsonServer, jsonConfig)
+	assert.Equal(t, float64(len(jsonConfig)), testutil.ToFloat64(jsonConfigBytes.WithLabelValues("team-a", "app-metrics")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(jsonConfigCollections.WithLabelValues("team-a", "app-metrics")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(jsonConfigCollectionItems.WithLabelValues("team-a", "app-metrics", "people")))
+	assert.Equal(t, 1, testutil.CollectAndCount(jsonConfigCollectionItems), "only array collections have items")
+
+	recordJsonConfig(jsonServer, `{"cars":[]}`)
+	assert.Equal(t, float64(1), testutil.ToFloat64(jsonConfigCollections.WithLabelValues("team-a", "app-metrics")))
+	assert.Equal(t, 1, testutil.CollectAndCount(jsonConfigCollectionItems), "removed collections are not reported")
+}
+
+func Test_recordStatusState(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-metrics", Namespace: "team-a"},
+		Status:     examplecomv1.JsonServerStatus{SyncState: examplecomv1.SyncStateError},
+	}
+	defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+
+	recordStatusState(jsonServer)
+	assert.Equal(t, float64(1), testutil.ToFloat64(statusState.WithLabelValues("team-a", "app-metrics", examplecomv1.SyncStateError)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(statusState.WithLabelValues("team-a", "app-metrics", examplecomv1.SyncStateSynced)))
+}
+
+func Test_deleteMetrics(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{ObjectMeta: metav1.ObjectMeta{Name: "app-metrics", Namespace: "team-a"}}
+	recordFixAction(jsonServer, CreateResourceFixAction(jsonServer, &appsv1.Deployment{}), nil)
+	recordDrift(jsonServer, "Deployment", []string{"replicas"})
+	_ = recordReconcileError("team-a", "app-metrics", errorCategoryConfig, assert.AnError)
+	assert.Equal(t, float64(1), testutil.ToFloat64(fixActionsTotal.WithLabelValues("team-a", "app-metrics", "Create-Deployment", "success")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(driftDetectionsTotal.WithLabelValues("team-a", "app-metrics", "Deployment", "replicas")))
+	assert.Equal(t, float64(1), testutil.ToFloat64(reconcileErrorsTotal.WithLabelValues("team-a", "app-metrics", errorCategoryConfig)))
+
+	deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+	assert.Equal(t, 0, testutil.CollectAndCount(fixActionsTotal))
+	assert.Equal(t, 0, testutil.CollectAndCount(driftDetectionsTotal))
+	assert.Equal(t, 0, testutil.CollectAndCount(reconcileErrorsTotal))
+}
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"strconv"
+)
+
+// pausedEnabled reports if reconciliation of jsonServer is paused by examplecomv1.PausedAnnotation.
+func pausedEnabled(jsonServer *examplecomv1.JsonServer) bool {
+	paused, err := strconv.ParseBool(jsonServer.Annotations[examplecomv1.PausedAnnotation])
+	return err == nil && paused
+}
+
+// pausedCondition returns Paused condition, nil if reconciliation is not paused.
+func pausedCondition(jsonServer *examplecomv1.JsonServer) *metav1.Condition {
+	if !pausedEnabled(jsonServer) {
+		return nil
+	}
+	return &metav1.Condition{
+		Type:               examplecomv1.ConditionPaused,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: jsonServer.Generation,
+		Reason:             "PausedByAnnotation",
+		Message:            fmt.Sprintf("changes of children are not made until %s is removed", examplecomv1.PausedAnnotation),
+	}
+}
+
+// emitPauseEvents emits an event when reconciliation is paused or resumed, the previous state is read from the Paused condition.
+func (r *JsonServerReconciler) emitPauseEvents(jsonServer *examplecomv1.JsonServer) {
+	wasPaused := meta.IsStatusConditionTrue(jsonServer.Status.Conditions, examplecomv1.ConditionPaused)
+	paused := pausedEnabled(jsonServer)
+	if paused && !wasPaused {
+		r.Recorder.Event(jsonServer, "Normal", "Paused", "Reconciliation paused, changes of children are not reverted")
+	} else if !paused && wasPaused {
+		r.Recorder.Event(jsonServer, "Normal", "Resumed", "Reconciliation resumed, differences of children are repaired")
+	}
+}
@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corevV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"strings"
+	"testing"
+)
+
+func Test_pausedEnabled(t *testing.T) {
+	tests := []struct {
+		name       string
+		annotation string
+		want       bool
+	}{
+		{name: "no annotation", want: false},
+		{name: "true", annotation: "true", want: true},
+		{name: "false", annotation: "false", want: false},
+		{name: "invalid", annotation: "yes please", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonServer := &examplecomv1.JsonServer{}
+			if tt.annotation != "" {
+				jsonServer.Annotations = map[string]string{examplecomv1.PausedAnnotation: tt.annotation}
+			}
+			assert.Equal(t, tt.want, pausedEnabled(jsonServer))
+		})
+	}
+}
+
+func TestJsonServerReconciler_Reconcile_paused(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-paused", Namespace: "team-a"},
+		Spec:       examplecomv1.JsonServerSpec{JsonConfig: `{"people":[]}`},
+	}
+	defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+	r := newTestReconciler(jsonServer)
+	c := r.Client
+	recorder := r.Recorder.(*record.FakeRecorder)
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)}
+	_, err := r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+
+	current := &examplecomv1.JsonServer{}
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, current))
+	current.Annotations = map[string]string{examplecomv1.PausedAnnotation: "true"}
+	assert.NoError(t, c.Update(context.TODO(), current))
+	deployment := &appsv1.Deployment{}
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, deployment))
+	deployment.Spec.Template.Spec.Containers[0].Image = "debug:latest"
+	assert.NoError(t, c.Update(context.TODO(), deployment))
+
+	// a hand-edited deployment is kept while paused
+	_, err = r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, deployment))
+	assert.Equal(t, "debug:latest", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, current))
+	assert.Equal(t, examplecomv1.SyncStatePaused, string(current.Status.SyncState))
+	assert.True(t, meta.IsStatusConditionTrue(current.Status.Conditions, examplecomv1.ConditionPaused))
+
+	// differences are repaired when resumed
+	delete(current.Annotations, examplecomv1.PausedAnnotation)
+	assert.NoError(t, c.Update(context.TODO(), current))
+	_, err = r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, deployment))
+	assert.NotEqual(t, "debug:latest", deployment.Spec.Template.Spec.Containers[0].Image)
+	assert.NoError(t, c.Get(context.TODO(), request.NamespacedName, current))
+	assert.Nil(t, meta.FindStatusCondition(current.Status.Conditions, examplecomv1.ConditionPaused))
+
+	events := make([]string, 0)
+	for len(recorder.Events) > 0 {
+		events = append(events, <-recorder.Events)
+	}
+	assert.Contains(t, events, "Normal Paused Reconciliation paused, changes of children are not reverted")
+	assert.Contains(t, events, "Normal Resumed Reconciliation resumed, differences of children are repaired")
+}
+
+func TestJsonServerReconciler_Reconcile_pausedRename(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-paused", Namespace: "team-a", UID: "uid-paused", Annotations: map[string]string{examplecomv1.PausedAnnotation: "true"}},
+		Spec:       examplecomv1.JsonServerSpec{JsonConfig: `{"people":[]}`, AdoptionPolicy: examplecomv1.AdoptionPolicyRename},
+	}
+	defer deleteMetrics(jsonServer.Namespace, jsonServer.Name)
+	r := newTestReconciler(jsonServer, &corevV1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-paused", Namespace: "team-a"}})
+	recorder := r.Recorder.(*record.FakeRecorder)
+	request := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)}
+
+	_, err := r.Reconcile(context.TODO(), request)
+	assert.NoError(t, err)
+	current := &examplecomv1.JsonServer{}
+	assert.NoError(t, r.Get(context.TODO(), request.NamespacedName, current))
+	assert.Empty(t, current.Status.ChildName, "children are not renamed while paused")
+	if assert.Len(t, current.Status.PlannedActions, 1) {
+		assert.True(t, strings.HasPrefix(current.Status.PlannedActions[0], "Rename-Children: children are named app-paused-"))
+	}
+	for len(recorder.Events) > 0 {
+		assert.NotContains(t, <-recorder.Events, "Renamed")
+	}
+}
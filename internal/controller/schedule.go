@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"time"
+)
+
+// scheduleLookback limits the search of the schedule in effect when schedules are added, so weekly schedules are found
+const scheduleLookback = 7 * 24 * time.Hour
+
+// scheduledScalings returns the latest change of replicas by schedules that is due since the last applied one
+// (nil if there is none) and the next change.
+func scheduledScalings(jsonServer *examplecomv1.JsonServer, now time.Time) (due *examplecomv1.ScheduledScaling, next *examplecomv1.ScheduledScaling) {
+	since := now.Add(-scheduleLookback)
+	if last := jsonServer.Status.LastScheduledScaling; last != nil && last.Time.After(since) {
+		since = last.Time.Time
+	}
+	for _, s := range jsonServer.Spec.Schedules {
+		schedule, err := s.Parse()
+		if err != nil {
+			// invalid schedules are rejected by the webhook
+			continue
+		}
+		for t := schedule.Next(since); !t.IsZero() && !t.After(now); t = schedule.Next(t) {
+			if due == nil || t.After(due.Time.Time) {
+				due = &examplecomv1.ScheduledScaling{Schedule: s.Name, Time: metav1.NewTime(t), Replicas: s.Replicas}
+			}
+		}
+		if t := schedule.Next(now); !t.IsZero() && (next == nil || t.Before(next.Time.Time)) {
+			next = &examplecomv1.ScheduledScaling{Schedule: s.Name, Time: metav1.NewTime(t), Replicas: s.Replicas}
+		}
+	}
+	return due, next
+}
+
+// applySchedules sets replicas of the due schedule, manual changes of replicas are kept until the next scheduled change.
+// In dry-run mode the change is only planned.
+func (r *JsonServerReconciler) applySchedules(ctx context.Context, jsonServer *examplecomv1.JsonServer, dryRun bool) error {
+	if len(jsonServer.Spec.Schedules) == 0 {
+		jsonServer.Status.LastScheduledScaling = nil
+		jsonServer.Status.NextScheduledScaling = nil
+		return nil
+	}
+	due, next := scheduledScalings(jsonServer, time.Now())
+	jsonServer.Status.NextScheduledScaling = next
+	if due == nil {
+		return nil
+	}
+	if jsonServer.Spec.Replicas == nil || *jsonServer.Spec.Replicas != due.Replicas {
+		previous := "default"
+		if jsonServer.Spec.Replicas != nil {
+			previous = fmt.Sprint(*jsonServer.Spec.Replicas)
+		}
+		if dryRun {
+			planAction(jsonServer, "ScheduledScaling-JsonServer", fmt.Sprintf("schedule %s would scale from %s to %d replicas", due.Schedule, previous, due.Replicas))
+			return nil
+		}
+		replicas := due.Replicas
+		jsonServer.Spec.Replicas = &replicas
+		if err := r.updateSpec(ctx, jsonServer); err != nil {
+			r.Recorder.Event(jsonServer, "Warning", "ScheduledScalingFailed", fmt.Sprintf("schedule %s: %s", due.Schedule, err))
+			return err
+		}
+		r.Recorder.Event(jsonServer, "Normal", "ScheduledScaling", fmt.Sprintf("schedule %s scaled from %s to %d replicas", due.Schedule, previous, due.Replicas))
+	}
+	jsonServer.Status.LastScheduledScaling = due
+	return nil
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+func Test_scheduledScalings(t *testing.T) {
+	// Wednesday
+	now := time.Date(2024, 1, 3, 12, 0, 0, 0, time.UTC)
+	schedules := []examplecomv1.ScalingSchedule{
+		{Name: "day", Schedule: "0 8 * * 1-5", Replicas: 3},
+		{Name: "night", Schedule: "0 20 * * 1-5", Replicas: 0},
+	}
+	tests := []struct {
+		name      string
+		schedules []examplecomv1.ScalingSchedule
+		last      *examplecomv1.ScheduledScaling
+		wantDue   *examplecomv1.ScheduledScaling
+		wantNext  *examplecomv1.ScheduledScaling
+	}{
+		{name: "no schedules"},
+		{
+			name:      "schedule in effect",
+			schedules: schedules,
+			wantDue:   &examplecomv1.ScheduledScaling{Schedule: "day", Time: metav1.NewTime(time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC)), Replicas: 3},
+			wantNext:  &examplecomv1.ScheduledScaling{Schedule: "night", Time: metav1.NewTime(time.Date(2024, 1, 3, 20, 0, 0, 0, time.UTC)), Replicas: 0},
+		},
+		{
+			name:      "already applied",
+			schedules: schedules,
+			last:      &examplecomv1.ScheduledScaling{Schedule: "day", Time: metav1.NewTime(time.Date(2024, 1, 3, 8, 0, 0, 0, time.UTC)), Replicas: 3},
+			wantNext:  &examplecomv1.ScheduledScaling{Schedule: "night", Time: metav1.NewTime(time.Date(2024, 1, 3, 20, 0, 0, 0, time.UTC)), Replicas: 0},
+		},
+		{
+			name:      "invalid schedule is skipped",
+			schedules: []examplecomv1.ScalingSchedule{{Name: "bad", Schedule: "never"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonServer := &examplecomv1.JsonServer{
+				Spec:   examplecomv1.JsonServerSpec{Schedules: tt.schedules},
+				Status: examplecomv1.JsonServerStatus{LastScheduledScaling: tt.last},
+			}
+			due, next := scheduledScalings(jsonServer, now)
+			assert.Equal(t, tt.wantDue, due)
+			assert.Equal(t, tt.wantNext, next)
+		})
+	}
+}
+
+func TestJsonServerReconciler_applySchedules(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-one", Namespace: "team-a"},
+		Spec: examplecomv1.JsonServerSpec{JsonConfig: "{}", Replicas: int32Ptr(1), Schedules: []examplecomv1.ScalingSchedule{
+			{Name: "always", Schedule: "* * * * *", Replicas: 2},
+		}},
+	}
+	r := newTestReconciler(jsonServer)
+	c := r.Client
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	assert.NoError(t, r.applySchedules(context.TODO(), jsonServer, false))
+	assert.Contains(t, <-recorder.Events, "Normal ScheduledScaling schedule always scaled from 1 to 2 replicas")
+	assert.NotNil(t, jsonServer.Status.LastScheduledScaling)
+	assert.NotNil(t, jsonServer.Status.NextScheduledScaling)
+	stored := &examplecomv1.JsonServer{}
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKeyFromObject(jsonServer), stored))
+	assert.Equal(t, int32(2), *stored.Spec.Replicas)
+
+	// manual scaling is kept until the next scheduled change
+	jsonServer.Spec.Replicas = int32Ptr(5)
+	jsonServer.Status.LastScheduledScaling.Time = metav1.NewTime(time.Now().Add(time.Minute))
+	assert.NoError(t, r.applySchedules(context.TODO(), jsonServer, false))
+	assert.Equal(t, int32(5), *jsonServer.Spec.Replicas)
+	assert.Empty(t, recorder.Events)
+}
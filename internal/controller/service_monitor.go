@@ -0,0 +1,56 @@
+package controller
+
+import (
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// serviceMonitorGVK is Prometheus Operator ServiceMonitor, it is handled as unstructured so Prometheus Operator is not a dependency of the operator
+var serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+
+// serviceMonitorInstalled is set on start of the operator if the ServiceMonitor CRD is present in the cluster
+var serviceMonitorInstalled = false
+
+// detectServiceMonitor checks if Prometheus Operator CRDs are installed.
+func detectServiceMonitor(mapper meta.RESTMapper) bool {
+	_, err := mapper.RESTMapping(serviceMonitorGVK.GroupKind(), serviceMonitorGVK.Version)
+	return err == nil
+}
+
+func newServiceMonitor() *unstructured.Unstructured {
+	serviceMonitor := &unstructured.Unstructured{}
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	return serviceMonitor
+}
+
+func createJsonServerServiceMonitorResource(jsonServer *examplecomv1.JsonServer) client.Object {
+	serviceMonitor := newServiceMonitor()
+	serviceMonitor.SetName(jsonServer.ChildName())
+	serviceMonitor.SetNamespace(jsonServer.Namespace)
+	serviceMonitor.SetOwnerReferences(createOwnerReferences(jsonServer, false))
+	endpoint := map[string]interface{}{"port": "metrics", "path": "/metrics"}
+	if metrics := jsonServer.Spec.Metrics; metrics != nil && metrics.ServiceMonitor != nil {
+		serviceMonitor.SetLabels(metrics.ServiceMonitor.Labels)
+		if metrics.ServiceMonitor.Interval != "" {
+			endpoint["interval"] = metrics.ServiceMonitor.Interval
+		}
+	}
+	serviceMonitor.Object["spec"] = map[string]interface{}{
+		"selector":  map[string]interface{}{"matchLabels": map[string]interface{}{"app": jsonServer.ChildName()}},
+		"endpoints": []interface{}{endpoint},
+	}
+	return serviceMonitor
+}
+
+// serviceMonitorRequired reports if a ServiceMonitor is created by the operator, it requires Prometheus Operator.
+func serviceMonitorRequired(jsonServer *examplecomv1.JsonServer) bool {
+	return serviceMonitorInstalled && jsonServer.Spec.Metrics != nil && jsonServer.Spec.Metrics.ServiceMonitor != nil
+}
+
+// metricsEnabled reports if json-server pods export request metrics.
+func metricsEnabled(jsonServer *examplecomv1.JsonServer) bool {
+	return jsonServer.Spec.Metrics != nil
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/apps/v1"
+	corevV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"testing"
+)
+
+func Test_createJsonServerServiceMonitorResource(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{Metrics: &examplecomv1.MetricsSpec{
+		ServiceMonitor: &examplecomv1.ServiceMonitorSpec{Interval: "30s", Labels: map[string]string{"release": "prometheus"}},
+	}}}
+	jsonServer.Name = "app-test"
+	jsonServer.Namespace = "mocks"
+	serviceMonitor := createJsonServerServiceMonitorResource(jsonServer).(*unstructured.Unstructured)
+	assert.Equal(t, "ServiceMonitor", objectType(serviceMonitor))
+	assert.Equal(t, map[string]string{"release": "prometheus"}, serviceMonitor.GetLabels())
+	selector, _, _ := unstructured.NestedStringMap(serviceMonitor.Object, "spec", "selector", "matchLabels")
+	assert.Equal(t, map[string]string{"app": "app-test"}, selector)
+	endpoints, _, _ := unstructured.NestedSlice(serviceMonitor.Object, "spec", "endpoints")
+	assert.Equal(t, []interface{}{map[string]interface{}{"port": "metrics", "path": "/metrics", "interval": "30s"}}, endpoints)
+
+	current := serviceMonitor.DeepCopy()
+	current.SetLabels(nil)
+	assert.Equal(t, []string{"label release"}, findResourceDifferences(serviceMonitor, current))
+}
+
+func Test_serviceMonitorRequired(t *testing.T) {
+	defer func(installed bool) { serviceMonitorInstalled = installed }(serviceMonitorInstalled)
+	withServiceMonitor := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{Metrics: &examplecomv1.MetricsSpec{ServiceMonitor: &examplecomv1.ServiceMonitorSpec{}}}}
+	metricsOnly := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{Metrics: &examplecomv1.MetricsSpec{}}}
+
+	serviceMonitorInstalled = false
+	assert.False(t, serviceMonitorRequired(withServiceMonitor), "ServiceMonitor CRD is missing")
+	serviceMonitorInstalled = true
+	assert.True(t, serviceMonitorRequired(withServiceMonitor))
+	assert.False(t, serviceMonitorRequired(metricsOnly))
+}
+
+func Test_metricsResources(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{JsonConfig: `{"people":[],"cars":[]}`, Metrics: &examplecomv1.MetricsSpec{}}}
+	jsonServer.Name = "app-test"
+	assert.True(t, sidecarRequired(jsonServer))
+
+	config := createSidecarConfig(jsonServer)
+	assert.Equal(t, ":9091", config.Metrics.Address)
+	assert.Equal(t, []string{"cars", "people"}, config.Metrics.Collections)
+
+	service := createJsonServerServiceResource(jsonServer).(*corevV1.Service)
+	assert.Equal(t, "http,metrics", servicePortNames(service))
+	withoutMetrics := service.DeepCopy()
+	withoutMetrics.Spec.Ports = withoutMetrics.Spec.Ports[:1]
+	assert.Equal(t, []string{"ports"}, findResourceDifferences(service, withoutMetrics))
+
+	sidecarContainer := findContainer((&JsonServerReconciler{}).createJsonServerDeploymentResource(jsonServer).(*v1.Deployment).Spec.Template.Spec.Containers, sidecarContainerName)
+	assert.Contains(t, sidecarContainer.Ports, corevV1.ContainerPort{Name: "metrics", ContainerPort: sidecarMetricsPort, Protocol: "TCP"})
+}
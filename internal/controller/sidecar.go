@@ -0,0 +1,374 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/m-szalik/json-server-operator/internal/sidecar"
+	"github.com/pkg/errors"
+	v1 "k8s.io/api/apps/v1"
+	corevV1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	sidecarContainerName        = "sidecar"
+	sidecarConfigField          = "sidecar.json"
+	sidecarConfigHashAnnotation = "jsonserver.example.com/sidecar-config-md5"
+	sidecarAdminPort            = 9090
+	sidecarMetricsPort          = 9091
+	// upstreamPort is the port of json-server when the sidecar is listening on the service port
+	upstreamPort   = 3100
+	secretsPath    = "/etc/json-server/secrets"
+	configMapsPath = "/etc/json-server/configmaps"
+	// adminTokenKey is the key of the token required by the sidecar admin endpoint /db in the admin Secret
+	adminTokenKey = "token"
+)
+
+// DefaultSidecarImage is the image of the sidecar container if the reconciler has none.
+const DefaultSidecarImage = "controller:latest"
+
+// sidecarStatsTimeout limits reading stats of a single pod
+const sidecarStatsTimeout = 2 * time.Second
+
+var (
+	sidecarHTTPClient = &http.Client{Timeout: 2 * time.Second}
+	// collectionPatternEscaper escapes a collection name used in a path.Match pattern
+	collectionPatternEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+)
+
+// sidecarRequired reports if json-server pods need the sidecar proxy.
+func sidecarRequired(jsonServer *examplecomv1.JsonServer) bool {
+	return jsonServer.Spec.Auth != nil || len(jsonServer.Spec.AccessRules) > 0 || jsonServer.Spec.TLS != nil || jsonServer.Spec.HTTP != nil ||
+		requestTrackingRequired(jsonServer) || metricsEnabled(jsonServer) || jsonServer.Spec.Logging != nil
+}
+
+// createSidecarConfig returns the configuration of the sidecar, paths refer to volumes added by injectSidecar.
+func createSidecarConfig(jsonServer *examplecomv1.JsonServer) sidecar.Config {
+	config := sidecar.Config{
+		ListenAddress:  fmt.Sprintf(":%d", port),
+		AdminAddress:   fmt.Sprintf(":%d", sidecarAdminPort),
+		AdminTokenFile: fmt.Sprintf("%s/%s/%s", secretsPath, adminSecretName(jsonServer), adminTokenKey),
+		Upstream:       fmt.Sprintf("http://127.0.0.1:%d", upstreamPort),
+	}
+	if auth := jsonServer.Spec.Auth; auth != nil {
+		config.Auth = &sidecar.AuthConfig{AnonymousRead: auth.AnonymousRead}
+		for _, token := range auth.BearerTokens {
+			config.Auth.BearerTokens = append(config.Auth.BearerTokens, sidecar.TokenConfig{
+				TokenFile: fmt.Sprintf("%s/%s/%s", secretsPath, token.SecretKeyRef.Name, token.SecretKeyRef.Key),
+				Principal: token.Principal,
+				Roles:     token.Roles,
+			})
+		}
+		for _, user := range auth.BasicAuth {
+			config.Auth.BasicUsers = append(config.Auth.BasicUsers, sidecar.BasicUserConfig{
+				UsernameFile: fmt.Sprintf("%s/%s/%s", secretsPath, user.SecretName, corevV1.BasicAuthUsernameKey),
+				PasswordFile: fmt.Sprintf("%s/%s/%s", secretsPath, user.SecretName, corevV1.BasicAuthPasswordKey),
+				Roles:        user.Roles,
+			})
+		}
+		if jwt := auth.JWT; jwt != nil {
+			config.Auth.JWT = &sidecar.JWTConfig{
+				JWKSFile:   fmt.Sprintf("%s/%s/%s", configMapsPath, jwt.JWKS.Name, jwt.JWKS.Key),
+				Issuer:     jwt.Issuer,
+				Audiences:  jwt.Audiences,
+				RolesClaim: jwt.RolesClaim,
+			}
+		}
+	}
+	if jsonServer.Spec.TLS != nil {
+		config.TLS = &sidecar.TLSConfig{
+			CertFile: fmt.Sprintf("%s/%s/%s", secretsPath, tlsSecretName(jsonServer), corevV1.TLSCertKey),
+			KeyFile:  fmt.Sprintf("%s/%s/%s", secretsPath, tlsSecretName(jsonServer), corevV1.TLSPrivateKeyKey),
+		}
+	}
+	if spec := jsonServer.Spec.HTTP; spec != nil {
+		if cors := spec.CORS; cors != nil {
+			config.CORS = &sidecar.CORSConfig{
+				AllowedOrigins:   cors.AllowedOrigins,
+				AllowedHeaders:   cors.AllowedHeaders,
+				ExposedHeaders:   cors.ExposedHeaders,
+				AllowCredentials: cors.AllowCredentials,
+			}
+			for _, method := range cors.AllowedMethods {
+				config.CORS.AllowedMethods = append(config.CORS.AllowedMethods, string(method))
+			}
+			if cors.MaxAgeSeconds != nil {
+				config.CORS.MaxAgeSeconds = int(*cors.MaxAgeSeconds)
+			}
+		}
+		for _, route := range spec.Headers {
+			config.Headers = append(config.Headers, sidecar.RouteHeadersConfig{Pattern: route.Path, Headers: route.Headers})
+		}
+	}
+	if metricsEnabled(jsonServer) {
+		config.Metrics = &sidecar.MetricsConfig{Address: fmt.Sprintf(":%d", sidecarMetricsPort), Collections: jsonCollections(jsonServer.Spec.JsonConfig)}
+	}
+	if logging := jsonServer.Spec.Logging; logging != nil {
+		config.Logging = &sidecar.LoggingConfig{
+			Format:        string(examplecomv1.LogFormatJSON),
+			SamplePercent: 100,
+			MaxBodyBytes:  int(logging.MaxBodyBytes),
+			RedactFields:  logging.RedactFields,
+		}
+		if logging.Format != "" {
+			config.Logging.Format = string(logging.Format)
+		}
+		if logging.SamplePercent != nil {
+			config.Logging.SamplePercent = int(*logging.SamplePercent)
+		}
+	}
+	for _, rule := range jsonServer.Spec.AccessRules {
+		ruleConfig := sidecar.AccessRuleConfig{
+			Pattern:    rule.Path,
+			Principals: rule.Principals,
+			Roles:      rule.Roles,
+		}
+		if rule.Collection != "" {
+			ruleConfig.Pattern = "/" + collectionPatternEscaper.Replace(rule.Collection)
+		}
+		for _, method := range rule.Methods {
+			ruleConfig.Methods = append(ruleConfig.Methods, string(method))
+		}
+		config.AccessRules = append(config.AccessRules, ruleConfig)
+	}
+	return config
+}
+
+func sidecarConfigJson(jsonServer *examplecomv1.JsonServer) string {
+	buf, _ := json.Marshal(createSidecarConfig(jsonServer))
+	return string(buf)
+}
+
+// injectSidecar puts the sidecar in front of json-server, which then listens on localhost only.
+func injectSidecar(deployment *v1.Deployment, jsonServer *examplecomv1.JsonServer, image string) {
+	podSpec := &deployment.Spec.Template.Spec
+	jsonServerContainer := findContainer(podSpec.Containers, containerName)
+	jsonServerContainer.Args = append([]string{"--host", "127.0.0.1", "--port", fmt.Sprint(upstreamPort)}, jsonServerContainer.Args...)
+	if jsonServer.Spec.Logging != nil {
+		// requests are logged by the sidecar
+		jsonServerContainer.Args = append([]string{"--quiet"}, jsonServerContainer.Args...)
+	}
+	jsonServerContainer.Ports = nil
+	container := corevV1.Container{
+		Name:    sidecarContainerName,
+		Image:   image,
+		Command: []string{"/sidecar", "--config", "/config/" + sidecarConfigField},
+		Ports: []corevV1.ContainerPort{
+			{Name: "http", ContainerPort: port, Protocol: "TCP"},
+			{Name: "admin", ContainerPort: sidecarAdminPort, Protocol: "TCP"},
+		},
+		VolumeMounts: []corevV1.VolumeMount{{Name: "json-config", ReadOnly: true, MountPath: "/config"}},
+		// probes of json-server are moved to the sidecar, as requests to json-server require credentials
+		LivenessProbe:  sidecarProbe(jsonServerContainer.LivenessProbe),
+		ReadinessProbe: sidecarProbe(jsonServerContainer.ReadinessProbe),
+	}
+	jsonServerContainer.LivenessProbe = nil
+	jsonServerContainer.ReadinessProbe = nil
+	if metricsEnabled(jsonServer) {
+		container.Ports = append(container.Ports, corevV1.ContainerPort{Name: "metrics", ContainerPort: sidecarMetricsPort, Protocol: "TCP"})
+	}
+	secretNames := []string{adminSecretName(jsonServer)}
+	configMapNames := make([]string, 0)
+	if auth := jsonServer.Spec.Auth; auth != nil {
+		for _, token := range auth.BearerTokens {
+			secretNames = appendUnique(secretNames, token.SecretKeyRef.Name)
+		}
+		for _, user := range auth.BasicAuth {
+			secretNames = appendUnique(secretNames, user.SecretName)
+		}
+		if auth.JWT != nil {
+			configMapNames = appendUnique(configMapNames, auth.JWT.JWKS.Name)
+		}
+	}
+	if jsonServer.Spec.TLS != nil {
+		secretNames = appendUnique(secretNames, tlsSecretName(jsonServer))
+	}
+	for _, name := range secretNames {
+		volumeName := "secret-" + name
+		podSpec.Volumes = append(podSpec.Volumes, corevV1.Volume{
+			Name:         volumeName,
+			VolumeSource: corevV1.VolumeSource{Secret: &corevV1.SecretVolumeSource{SecretName: name}},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corevV1.VolumeMount{Name: volumeName, ReadOnly: true, MountPath: secretsPath + "/" + name})
+	}
+	for _, name := range configMapNames {
+		volumeName := "configmap-" + name
+		podSpec.Volumes = append(podSpec.Volumes, corevV1.Volume{
+			Name: volumeName,
+			VolumeSource: corevV1.VolumeSource{ConfigMap: &corevV1.ConfigMapVolumeSource{
+				LocalObjectReference: corevV1.LocalObjectReference{Name: name},
+			}},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corevV1.VolumeMount{Name: volumeName, ReadOnly: true, MountPath: configMapsPath + "/" + name})
+	}
+	podSpec.Containers = append(podSpec.Containers, container)
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+	// the sidecar reads its configuration on start, pods are restarted when it changes
+	deployment.Spec.Template.Annotations[sidecarConfigHashAnnotation] = md5hash(sidecarConfigJson(jsonServer))
+}
+
+// sidecarProbe replaces an HTTP probe with a check of the sidecar /healthz endpoint, which checks json-server.
+func sidecarProbe(probe *corevV1.Probe) *corevV1.Probe {
+	if probe == nil || probe.HTTPGet == nil {
+		return probe
+	}
+	p := probe.DeepCopy()
+	p.HTTPGet.Path = "/healthz"
+	p.HTTPGet.Port = intstr.FromString("admin")
+	p.HTTPGet.Scheme = corevV1.URISchemeHTTP
+	return p
+}
+
+// collectSidecarStats returns stats of sidecars of running pods of jsonServer, pods are asked in parallel.
+// Stats of pods which answered are returned also with an error about the others.
+func (r *JsonServerReconciler) collectSidecarStats(ctx context.Context, jsonServer *examplecomv1.JsonServer) ([]sidecar.Stats, error) {
+	pods := &corevV1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(jsonServer.Namespace), client.MatchingLabels{"app": jsonServer.ChildName()}); err != nil {
+		return nil, errors.Wrapf(err, "cannot list pods of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		stats    = make([]sidecar.Stats, 0, len(pods.Items))
+		failures = make([]string, 0)
+	)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corevV1.PodRunning || pod.Status.PodIP == "" || findContainer(pod.Spec.Containers, sidecarContainerName) == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(name string, podIP string) {
+			defer wg.Done()
+			podCtx, cancel := context.WithTimeout(ctx, sidecarStatsTimeout)
+			defer cancel()
+			podStats, err := fetchSidecarStats(podCtx, podIP)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("pod %s: %s", name, err))
+				return
+			}
+			stats = append(stats, podStats)
+		}(pod.Name, pod.Status.PodIP)
+	}
+	wg.Wait()
+	if len(failures) > 0 {
+		sort.Strings(failures)
+		return stats, fmt.Errorf("cannot get stats of %d pods: %s", len(failures), strings.Join(failures, "; "))
+	}
+	return stats, nil
+}
+
+func fetchSidecarStats(ctx context.Context, podIP string) (sidecar.Stats, error) {
+	stats := sidecar.Stats{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s:%d/stats", podIP, sidecarAdminPort), nil)
+	if err != nil {
+		return stats, err
+	}
+	resp, err := sidecarHTTPClient.Do(req)
+	if err != nil {
+		return stats, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return stats, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	return stats, err
+}
+
+func adminSecretName(jsonServer *examplecomv1.JsonServer) string {
+	return jsonServer.ChildName() + "-admin"
+}
+
+// createAdminSecret returns a Secret with a random token, the operator reads data of the sidecar with it.
+func createAdminSecret(jsonServer *examplecomv1.JsonServer) (*corevV1.Secret, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, errors.Wrapf(err, "cannot generate admin token")
+	}
+	return &corevV1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            adminSecretName(jsonServer),
+			Namespace:       jsonServer.Namespace,
+			Labels:          map[string]string{"app": jsonServer.ChildName()},
+			OwnerReferences: createOwnerReferences(jsonServer, false),
+		},
+		Type: corevV1.SecretTypeOpaque,
+		Data: map[string][]byte{adminTokenKey: []byte(hex.EncodeToString(token))},
+	}, nil
+}
+
+// adminSecretFixActions creates the admin Secret of the sidecar, the token is never changed so running pods keep working.
+func (r *JsonServerReconciler) adminSecretFixActions(ctx context.Context, jsonServer *examplecomv1.JsonServer) ([]FixAction, string, error) {
+	current := &corevV1.Secret{}
+	// Secrets are not cached, the operator reads only its own
+	err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: jsonServer.Namespace, Name: adminSecretName(jsonServer)}, current)
+	if err != nil && !k8errors.IsNotFound(err) {
+		return nil, "", errors.Wrapf(err, "cannot get admin Secret of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	exists := err == nil
+	if !sidecarRequired(jsonServer) {
+		if exists && metav1.IsControlledBy(current, jsonServer) {
+			return []FixAction{DeleteResourceFixAction(jsonServer, current)}, "", nil
+		}
+		return nil, "", nil
+	}
+	if exists {
+		if !metav1.IsControlledBy(current, jsonServer) {
+			return nil, conflictMessage(current), nil
+		}
+		return nil, "", nil
+	}
+	desired, err := createAdminSecret(jsonServer)
+	if err != nil {
+		return nil, "", err
+	}
+	return []FixAction{CreateResourceFixAction(jsonServer, desired)}, "", nil
+}
+
+// adminToken returns the token of the sidecar admin endpoint of jsonServer.
+func (r *JsonServerReconciler) adminToken(ctx context.Context, jsonServer *examplecomv1.JsonServer) (string, error) {
+	secret := &corevV1.Secret{}
+	if err := r.APIReader.Get(ctx, client.ObjectKey{Namespace: jsonServer.Namespace, Name: adminSecretName(jsonServer)}, secret); err != nil {
+		return "", errors.Wrapf(err, "cannot get admin Secret of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	return string(secret.Data[adminTokenKey]), nil
+}
+
+// jsonCollections returns sorted collections (top-level keys) of jsonConfig.
+func jsonCollections(jsonConfig string) []string {
+	collections := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(jsonConfig), &collections); err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(collections))
+	for name := range collections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func appendUnique(items []string, item string) []string {
+	for _, i := range items {
+		if i == item {
+			return items
+		}
+	}
+	return append(items, item)
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/m-szalik/json-server-operator/internal/sidecar"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/apps/v1"
+	corevV1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"testing"
+)
+
+func Test_injectSidecar(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{
+		JsonConfig: "{}",
+		ServerSettings: examplecomv1.ServerSettings{
+			ReadinessProbe: &corevV1.Probe{ProbeHandler: corevV1.ProbeHandler{HTTPGet: &corevV1.HTTPGetAction{Path: "/", Port: intstr.FromString("http")}}},
+		},
+		Auth: &examplecomv1.AuthSpec{
+			BearerTokens: []examplecomv1.BearerTokenAuth{
+				{SecretKeyRef: corevV1.SecretKeySelector{LocalObjectReference: corevV1.LocalObjectReference{Name: "tokens"}, Key: "ci"}, Principal: "ci"},
+				{SecretKeyRef: corevV1.SecretKeySelector{LocalObjectReference: corevV1.LocalObjectReference{Name: "tokens"}, Key: "qa"}, Principal: "qa"},
+			},
+			BasicAuth: []examplecomv1.BasicAuthUser{{SecretName: "john"}},
+		},
+	}}
+	jsonServer.Name = "app-test"
+	deployment := (&JsonServerReconciler{}).createJsonServerDeploymentResource(jsonServer).(*v1.Deployment)
+	containers := deployment.Spec.Template.Spec.Containers
+	assert.Len(t, containers, 2)
+	jsonServerContainer := findContainer(containers, containerName)
+	assert.Equal(t, []string{"--host", "127.0.0.1", "--port", "3100"}, jsonServerContainer.Args[:4])
+	assert.Empty(t, jsonServerContainer.Ports)
+	assert.Nil(t, jsonServerContainer.ReadinessProbe)
+	sidecarContainer := findContainer(containers, sidecarContainerName)
+	assert.Equal(t, "/healthz", sidecarContainer.ReadinessProbe.HTTPGet.Path)
+	assert.Equal(t, intstr.FromString("admin"), sidecarContainer.ReadinessProbe.HTTPGet.Port)
+	// config and one volume per Secret, including the admin Secret
+	assert.Len(t, sidecarContainer.VolumeMounts, 4)
+	assert.NotEmpty(t, deployment.Spec.Template.Annotations[sidecarConfigHashAnnotation])
+
+	config := createSidecarConfig(jsonServer)
+	assert.Equal(t, "/etc/json-server/secrets/tokens/qa", config.Auth.BearerTokens[1].TokenFile)
+	assert.Equal(t, "/etc/json-server/secrets/john/password", config.Auth.BasicUsers[0].PasswordFile)
+	assert.Equal(t, "/etc/json-server/secrets/app-test-admin/token", config.AdminTokenFile)
+}
+
+func Test_sidecarNotRequired(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{JsonConfig: "{}"}}
+	deployment := (&JsonServerReconciler{}).createJsonServerDeploymentResource(jsonServer).(*v1.Deployment)
+	assert.Len(t, deployment.Spec.Template.Spec.Containers, 1)
+}
+
+func Test_createSidecarConfig_accessRules(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{
+		AccessRules: []examplecomv1.AccessRule{
+			{Collection: "posts", Methods: []examplecomv1.HTTPMethod{"GET"}},
+			{Collection: "what?", Roles: []string{"writer"}},
+			{Path: "/comments/*"},
+		},
+	}}
+	assert.True(t, sidecarRequired(jsonServer))
+	config := createSidecarConfig(jsonServer)
+	assert.Nil(t, config.Auth)
+	assert.Equal(t, []sidecar.AccessRuleConfig{
+		{Pattern: "/posts", Methods: []string{"GET"}},
+		{Pattern: `/what\?`, Roles: []string{"writer"}},
+		{Pattern: "/comments/*"},
+	}, config.AccessRules)
+}
+
+func Test_createSidecarConfig_logging(t *testing.T) {
+	samplePercent := int32(10)
+	jsonServer := &examplecomv1.JsonServer{Spec: examplecomv1.JsonServerSpec{JsonConfig: "{}", Logging: &examplecomv1.LoggingSpec{}}}
+	assert.True(t, sidecarRequired(jsonServer))
+	assert.Equal(t, &sidecar.LoggingConfig{Format: "json", SamplePercent: 100}, createSidecarConfig(jsonServer).Logging, "defaults")
+
+	jsonServer.Spec.Logging = &examplecomv1.LoggingSpec{Format: examplecomv1.LogFormatText, SamplePercent: &samplePercent, MaxBodyBytes: 512, RedactFields: []string{"password"}}
+	assert.Equal(t, &sidecar.LoggingConfig{Format: "text", SamplePercent: 10, MaxBodyBytes: 512, RedactFields: []string{"password"}}, createSidecarConfig(jsonServer).Logging)
+
+	deployment := (&JsonServerReconciler{}).createJsonServerDeploymentResource(jsonServer).(*v1.Deployment)
+	assert.Equal(t, "--quiet", findContainer(deployment.Spec.Template.Spec.Containers, containerName).Args[0], "json-server does not log requests")
+}
+
+func TestJsonServerReconciler_adminSecretFixActions(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-test", Namespace: "team-a", UID: "uid-1"},
+		Spec:       examplecomv1.JsonServerSpec{JsonConfig: "{}", Auth: &examplecomv1.AuthSpec{AnonymousRead: true}},
+	}
+	r := newTestReconciler()
+	c := r.Client
+
+	actions, conflict, err := r.adminSecretFixActions(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Empty(t, conflict)
+	if assert.Len(t, actions, 1) {
+		assert.Equal(t, "Create-Secret", actions[0].Reason())
+		assert.NoError(t, actions[0].Fix(context.TODO(), r))
+	}
+	token, err := r.adminToken(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Len(t, token, 64)
+	actions, _, err = r.adminSecretFixActions(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	assert.Empty(t, actions, "the token is kept")
+
+	jsonServer.Spec.Auth = nil
+	actions, _, err = r.adminSecretFixActions(context.TODO(), jsonServer)
+	assert.NoError(t, err)
+	if assert.Len(t, actions, 1) {
+		assert.Equal(t, "Delete-Secret", actions[0].Reason())
+	}
+
+	other := jsonServer.DeepCopy()
+	other.Name = "app-other"
+	other.Spec.Auth = &examplecomv1.AuthSpec{AnonymousRead: true}
+	assert.NoError(t, c.Create(context.TODO(), &corevV1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "app-other-admin", Namespace: "team-a"}}))
+	actions, conflict, err = r.adminSecretFixActions(context.TODO(), other)
+	assert.NoError(t, err)
+	assert.Empty(t, actions)
+	assert.Equal(t, "Secret team-a/app-other-admin exists and is not owned by the JsonServer", conflict)
+}
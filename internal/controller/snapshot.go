@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	"io"
+	corevV1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"time"
+)
+
+const (
+	// snapshotFinalizer keeps JsonServer until a snapshot of its data is saved
+	snapshotFinalizer           = "jsonserver.example.com/snapshot"
+	snapshotField               = "db.json"
+	snapshotSourceAnnotation    = "jsonserver.example.com/snapshot-source"
+	snapshotTimeAnnotation      = "jsonserver.example.com/snapshot-time"
+	snapshotJsonConfigSource    = "jsonConfig"
+	maxSnapshotBytes            = 1024 * 1024
+	snapshotLabel               = "jsonserver.example.com/snapshot"
+	snapshotConfigMapNameSuffix = "-snapshot"
+)
+
+// snapshotRequired reports if data of jsonServer is saved when it is deleted.
+func snapshotRequired(jsonServer *examplecomv1.JsonServer) bool {
+	return jsonServer.Spec.DeletionProtection != nil && jsonServer.Spec.DeletionProtection.Snapshot
+}
+
+// syncSnapshotFinalizer adds or removes the snapshot finalizer, it returns true if jsonServer was updated.
+func (r *JsonServerReconciler) syncSnapshotFinalizer(ctx context.Context, jsonServer *examplecomv1.JsonServer, dryRun bool) (bool, error) {
+	required := snapshotRequired(jsonServer)
+	if dryRun {
+		if required && !controllerutil.ContainsFinalizer(jsonServer, snapshotFinalizer) {
+			planAction(jsonServer, "AddFinalizer-JsonServer", snapshotFinalizer)
+		} else if !required && controllerutil.ContainsFinalizer(jsonServer, snapshotFinalizer) {
+			planAction(jsonServer, "RemoveFinalizer-JsonServer", snapshotFinalizer)
+		}
+		return false, nil
+	}
+	var changed bool
+	if required {
+		changed = controllerutil.AddFinalizer(jsonServer, snapshotFinalizer)
+	} else {
+		changed = controllerutil.RemoveFinalizer(jsonServer, snapshotFinalizer)
+	}
+	if !changed {
+		return false, nil
+	}
+	if err := r.Update(ctx, jsonServer); err != nil {
+		return false, errors.Wrapf(err, "cannot update finalizers of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	return true, nil
+}
+
+// finalize saves a snapshot of data of a deleted jsonServer and removes the finalizer, so children can be garbage collected.
+func (r *JsonServerReconciler) finalize(ctx context.Context, jsonServer *examplecomv1.JsonServer) error {
+	if !controllerutil.ContainsFinalizer(jsonServer, snapshotFinalizer) {
+		return nil
+	}
+	data, source, err := r.fetchSnapshotData(ctx, jsonServer)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxSnapshotBytes {
+		return fmt.Errorf("data of %s has %d bytes, it does not fit into a ConfigMap", client.ObjectKeyFromObject(jsonServer), len(data))
+	}
+	snapshot := createSnapshotConfigMap(jsonServer, data, source)
+	if err := r.Create(ctx, snapshot); err != nil {
+		if !k8errors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "cannot create snapshot %s", client.ObjectKeyFromObject(snapshot))
+		}
+		if err := r.Update(ctx, snapshot); err != nil {
+			return errors.Wrapf(err, "cannot update snapshot %s", client.ObjectKeyFromObject(snapshot))
+		}
+	}
+	r.Recorder.Eventf(jsonServer, "Normal", "SnapshotSaved", "data from %s saved in ConfigMap %s", source, snapshot.Name)
+	controllerutil.RemoveFinalizer(jsonServer, snapshotFinalizer)
+	if err := r.Update(ctx, jsonServer); err != nil {
+		return errors.Wrapf(err, "cannot remove finalizer of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	return nil
+}
+
+// planFinalize reports in status what finalize would do, a deleted jsonServer is kept in dry-run mode.
+func (r *JsonServerReconciler) planFinalize(ctx context.Context, jsonServer *examplecomv1.JsonServer) error {
+	if !controllerutil.ContainsFinalizer(jsonServer, snapshotFinalizer) {
+		return nil
+	}
+	previous := jsonServer.Status.PlannedActions
+	jsonServer.Status.PlannedActions = nil
+	planAction(jsonServer, "Snapshot-JsonServer", fmt.Sprintf("data saved in ConfigMap %s, finalizer %s removed",
+		jsonServer.Name+snapshotConfigMapNameSuffix, snapshotFinalizer))
+	if equality.Semantic.DeepEqual(previous, jsonServer.Status.PlannedActions) {
+		return nil
+	}
+	r.emitPlanEvents(jsonServer, previous)
+	if err := r.Status().Update(ctx, jsonServer); err != nil {
+		return errors.Wrapf(err, "cannot update status of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	return nil
+}
+
+func createSnapshotConfigMap(jsonServer *examplecomv1.JsonServer, data string, source string) *corevV1.ConfigMap {
+	return &corevV1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      jsonServer.Name + snapshotConfigMapNameSuffix,
+			Namespace: jsonServer.Namespace,
+			Labels:    map[string]string{"app": jsonServer.Name, snapshotLabel: "true"},
+			Annotations: map[string]string{
+				snapshotSourceAnnotation: source,
+				snapshotTimeAnnotation:   time.Now().UTC().Format(time.RFC3339),
+			},
+		},
+		Data: map[string]string{snapshotField: data},
+	}
+}
+
+// fetchSnapshotData returns the database of a running pod of jsonServer and the pod name,
+// or the rendered jsonConfig when no pod is running.
+func (r *JsonServerReconciler) fetchSnapshotData(ctx context.Context, jsonServer *examplecomv1.JsonServer) (string, string, error) {
+	pods := &corevV1.PodList{}
+	if err := r.APIReader.List(ctx, pods, client.InNamespace(jsonServer.Namespace), client.MatchingLabels{"app": jsonServer.ChildName()}); err != nil {
+		return "", "", errors.Wrapf(err, "cannot list pods of %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corevV1.PodRunning || pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		url := fmt.Sprintf("http://%s:%d/db", pod.Status.PodIP, port)
+		token := ""
+		if findContainer(pod.Spec.Containers, sidecarContainerName) != nil {
+			// json-server listens on localhost only, the sidecar serves its database on the admin port to the operator
+			url = fmt.Sprintf("http://%s:%d/db", pod.Status.PodIP, sidecarAdminPort)
+			var err error
+			if token, err = r.adminToken(ctx, jsonServer); err != nil {
+				return "", "", err
+			}
+		}
+		data, err := fetchPodData(ctx, url, token)
+		if err != nil {
+			return "", "", errors.Wrapf(err, "cannot get data of pod %s", pod.Name)
+		}
+		return data, pod.Name, nil
+	}
+	jsonConfig, err := jsonServer.RenderedJsonConfig()
+	if err != nil {
+		return "", "", errors.Wrapf(err, "cannot render jsonConfig template")
+	}
+	return jsonConfig, snapshotJsonConfigSource, nil
+}
+
+func fetchPodData(ctx context.Context, url string, token string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := sidecarHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, maxSnapshotBytes+1))
+	return string(buf), err
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	corevV1 "k8s.io/api/core/v1"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"net/http"
+	"net/http/httptest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestJsonServerReconciler_finalize(t *testing.T) {
+	now := metav1.Now()
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-one", Namespace: "team-a", Finalizers: []string{snapshotFinalizer}, DeletionTimestamp: &now},
+		Spec: examplecomv1.JsonServerSpec{
+			JsonConfig:         `{"people": []}`,
+			DeletionProtection: &examplecomv1.DeletionProtectionSpec{Snapshot: true},
+		},
+	}
+	r := newTestReconciler(jsonServer)
+	c := r.Client
+
+	assert.NoError(t, r.finalize(context.TODO(), jsonServer))
+	snapshot := &corevV1.ConfigMap{}
+	assert.NoError(t, c.Get(context.TODO(), client.ObjectKey{Namespace: "team-a", Name: "app-one-snapshot"}, snapshot))
+	assert.Equal(t, `{"people": []}`, snapshot.Data[snapshotField])
+	assert.Equal(t, snapshotJsonConfigSource, snapshot.Annotations[snapshotSourceAnnotation])
+	assert.Empty(t, snapshot.OwnerReferences)
+	// the finalizer was the last one, the JsonServer is gone
+	err := c.Get(context.TODO(), client.ObjectKeyFromObject(jsonServer), &examplecomv1.JsonServer{})
+	assert.True(t, k8errors.IsNotFound(err))
+}
+
+func TestJsonServerReconciler_syncSnapshotFinalizer(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-one", Namespace: "team-a"},
+		Spec:       examplecomv1.JsonServerSpec{DeletionProtection: &examplecomv1.DeletionProtectionSpec{Snapshot: true}},
+	}
+	r := newTestReconciler(jsonServer)
+
+	changed, err := r.syncSnapshotFinalizer(context.TODO(), jsonServer, false)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, []string{snapshotFinalizer}, jsonServer.Finalizers)
+	changed, err = r.syncSnapshotFinalizer(context.TODO(), jsonServer, false)
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	jsonServer.Spec.DeletionProtection = nil
+	changed, err = r.syncSnapshotFinalizer(context.TODO(), jsonServer, false)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Empty(t, jsonServer.Finalizers)
+}
+
+func Test_fetchPodData(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer admin-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"people":[{"id":1}]}`))
+	}))
+	defer server.Close()
+	data, err := fetchPodData(context.TODO(), server.URL+"/db", "admin-token")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"people":[{"id":1}]}`, data)
+	_, err = fetchPodData(context.TODO(), server.URL+"/db", "")
+	assert.Error(t, err)
+	_, err = fetchPodData(context.TODO(), server.URL+"/other", "admin-token")
+	assert.Error(t, err)
+}
@@ -0,0 +1,13 @@
+package controller
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// tracer creates spans of reconciliation, they are not exported until a TracerProvider is registered by tracing.Setup
+var tracer = otel.Tracer("github.com/m-szalik/json-server-operator/internal/controller")
+
+func jsonServerAttributes(namespace, name string) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.String("jsonserver.namespace", namespace), attribute.String("jsonserver.name", name)}
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+)
+
+func TestJsonServerReconciler_Reconcile_spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-traced", Namespace: "team-a"},
+		Spec:       examplecomv1.JsonServerSpec{JsonConfig: `{"people":[]}`},
+	}
+	r := newTestReconciler(jsonServer)
+
+	_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)})
+	assert.NoError(t, err)
+
+	spans := map[string][]sdktrace.ReadOnlySpan{}
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = append(spans[span.Name()], span)
+	}
+	assert.Len(t, spans["JsonServerReconciler.Reconcile"], 1)
+	assert.Len(t, spans["JsonServerReconciler.validateResources"], 1)
+	assert.Len(t, spans["JsonServerReconciler.updateStatus"], 1)
+	reconcile := spans["JsonServerReconciler.Reconcile"][0]
+	assert.Contains(t, reconcile.Attributes(), attribute.String("jsonserver.name", "app-traced"))
+	reasons := make([]string, 0)
+	for _, span := range spans["FixAction.Fix"] {
+		assert.Equal(t, reconcile.SpanContext().SpanID(), span.Parent().SpanID(), "fix actions are children of reconcile")
+		for _, a := range span.Attributes() {
+			if a.Key == "fixaction.reason" {
+				reasons = append(reasons, a.Value.AsString())
+			}
+		}
+	}
+	assert.Contains(t, reasons, "Create-Deployment")
+	assert.Contains(t, reasons, "Create-ConfigMap")
+}
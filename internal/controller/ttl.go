@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/pkg/errors"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"time"
+)
+
+// expirationTime returns when jsonServer is deleted by its TTL, nil if it has no TTL.
+// The idle TTL counts from status.lastRequestTime.
+func expirationTime(jsonServer *examplecomv1.JsonServer) *time.Time {
+	var expiration *time.Time
+	if ttl := jsonServer.Spec.TTLSecondsAfterCreation; ttl != nil {
+		t := jsonServer.CreationTimestamp.Add(time.Duration(*ttl) * time.Second)
+		expiration = &t
+	}
+	if idle := jsonServer.Spec.IdleTTLMinutes; idle != nil && jsonServer.Status.LastRequestTime != nil {
+		t := jsonServer.Status.LastRequestTime.Add(time.Duration(*idle) * time.Minute)
+		if expiration == nil || t.Before(*expiration) {
+			expiration = &t
+		}
+	}
+	return expiration
+}
+
+// expiresIn formats the remaining lifetime truncated to hours, or to minutes in the last hour, so status.expiresIn
+// changes (and is updated) at most once an hour before that. It also returns when the formatted value changes.
+func expiresIn(expiration time.Time, now time.Time) (string, time.Time) {
+	remaining := expiration.Sub(now)
+	unit, suffix := time.Hour, "h"
+	if remaining < time.Hour {
+		unit, suffix = time.Minute, "m"
+	}
+	if remaining < time.Minute {
+		return "<1m", expiration
+	}
+	truncated := remaining.Truncate(unit)
+	return fmt.Sprintf("%d%s", truncated/unit, suffix), now.Add(remaining - truncated)
+}
+
+// refreshLastRequestTime sets status.lastRequestTime from stats of sidecars, the status is saved by updateStatus.
+// The time starts when idleTTLMinutes or idle is set, so existing JsonServers are not deleted or scaled down immediately.
+func (r *JsonServerReconciler) refreshLastRequestTime(ctx context.Context, jsonServer *examplecomv1.JsonServer) error {
+	if !requestTrackingRequired(jsonServer) {
+		jsonServer.Status.LastRequestTime = nil
+		return nil
+	}
+	if jsonServer.Status.LastRequestTime == nil {
+		jsonServer.Status.LastRequestTime = &metav1.Time{Time: time.Now()}
+	}
+	// stats of pods which answered are used, the error prevents scaling to zero on a partial view
+	stats, err := r.collectSidecarStats(ctx, jsonServer)
+	for _, s := range stats {
+		// a started pod counts as a request, LastRequestTime is the start time of pods without requests
+		if s.LastRequestTime.After(jsonServer.Status.LastRequestTime.Time) {
+			jsonServer.Status.LastRequestTime = &metav1.Time{Time: s.LastRequestTime}
+		}
+	}
+	return err
+}
+
+// expire deletes jsonServer which has outlived its TTL.
+func (r *JsonServerReconciler) expire(ctx context.Context, jsonServer *examplecomv1.JsonServer, expiration time.Time) error {
+	r.Recorder.Event(jsonServer, "Normal", "Expired", fmt.Sprintf("TTL expired at %s, deleting JsonServer", expiration.UTC().Format(time.RFC3339)))
+	if err := r.Delete(ctx, jsonServer); err != nil && !k8errors.IsNotFound(err) {
+		return errors.Wrapf(err, "cannot delete expired %s", client.ObjectKeyFromObject(jsonServer))
+	}
+	return nil
+}
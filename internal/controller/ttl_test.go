@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"context"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
+	"github.com/stretchr/testify/assert"
+	k8errors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"testing"
+	"time"
+)
+
+func Test_expirationTime(t *testing.T) {
+	created := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	lastRequest := metav1.NewTime(created.Add(time.Hour))
+	tests := []struct {
+		name           string
+		ttlSeconds     *int32
+		idleMinutes    *int32
+		lastRequest    *metav1.Time
+		wantExpiration *time.Time
+	}{
+		{name: "no ttl"},
+		{name: "ttl after creation", ttlSeconds: int32Ptr(600), wantExpiration: timePtr(created.Add(10 * time.Minute))},
+		{name: "idle ttl", idleMinutes: int32Ptr(30), lastRequest: &lastRequest, wantExpiration: timePtr(created.Add(90 * time.Minute))},
+		{name: "idle ttl without last request", idleMinutes: int32Ptr(30)},
+		{name: "earlier of both", ttlSeconds: int32Ptr(7200), idleMinutes: int32Ptr(30), lastRequest: &lastRequest, wantExpiration: timePtr(created.Add(90 * time.Minute))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jsonServer := &examplecomv1.JsonServer{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+				Spec:       examplecomv1.JsonServerSpec{TTLSecondsAfterCreation: tt.ttlSeconds, IdleTTLMinutes: tt.idleMinutes},
+				Status:     examplecomv1.JsonServerStatus{LastRequestTime: tt.lastRequest},
+			}
+			assert.Equal(t, tt.wantExpiration, expirationTime(jsonServer))
+		})
+	}
+}
+
+func Test_expiresIn(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		remaining   time.Duration
+		want        string
+		wantChanges time.Duration
+	}{
+		{remaining: 26*time.Hour + 10*time.Minute, want: "26h", wantChanges: 10 * time.Minute},
+		{remaining: time.Hour, want: "1h", wantChanges: 0},
+		{remaining: 59*time.Minute + 30*time.Second, want: "59m", wantChanges: 30 * time.Second},
+		{remaining: 30 * time.Second, want: "<1m", wantChanges: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.remaining.String(), func(t *testing.T) {
+			got, changesAt := expiresIn(now.Add(tt.remaining), now)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, now.Add(tt.wantChanges), changesAt)
+		})
+	}
+}
+
+func TestJsonServerReconciler_Reconcile_expired(t *testing.T) {
+	jsonServer := &examplecomv1.JsonServer{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-pr-12", Namespace: "team-a", CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour))},
+		Spec:       examplecomv1.JsonServerSpec{JsonConfig: "{}", TTLSecondsAfterCreation: int32Ptr(60)},
+	}
+	r := newTestReconciler(jsonServer)
+	c := r.Client
+	recorder := r.Recorder.(*record.FakeRecorder)
+
+	_, err := r.Reconcile(context.TODO(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(jsonServer)})
+	assert.NoError(t, err)
+	assert.Contains(t, <-recorder.Events, "Normal Expired TTL expired at")
+	err = c.Get(context.TODO(), client.ObjectKeyFromObject(jsonServer), &examplecomv1.JsonServer{})
+	assert.True(t, k8errors.IsNotFound(err))
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -2,10 +2,27 @@ package controller
 
 import (
 	"fmt"
+	examplecomv1 "github.com/m-szalik/json-server-operator/api/v1"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"testing"
 )
 
+// newTestReconciler returns a reconciler with a fake client storing objs, JsonServers and JsonServerQuotas have the status subresource.
+func newTestReconciler(objs ...client.Object) *JsonServerReconciler {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(examplecomv1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).
+		WithStatusSubresource(&examplecomv1.JsonServer{}, &examplecomv1.JsonServerQuota{}).Build()
+	return &JsonServerReconciler{Client: c, APIReader: c, Scheme: scheme, Recorder: record.NewFakeRecorder(20)}
+}
+
 func Test_validateJson(t *testing.T) {
 	tests := []struct {
 		name    string
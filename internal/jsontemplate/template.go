@@ -0,0 +1,250 @@
+package jsontemplate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/pkg/errors"
+	"hash/fnv"
+	"math/rand"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+const (
+	// maxSeqLength limits the number of items a single seq call can generate.
+	maxSeqLength = 10000
+	// maxSeqItems limits the number of items generated by all seq calls, e.g. in nested ranges.
+	maxSeqItems = 100000
+	// maxOutputBytes limits the rendered output, it has to fit into a ConfigMap.
+	maxOutputBytes = 1 << 20
+	// renderTimeout limits the time of rendering, templates are rendered by the webhook and the controller.
+	renderTimeout = 2 * time.Second
+)
+
+var firstNames = []string{
+	"Alice", "Bob", "Carol", "David", "Emma", "Frank", "Grace", "Henry", "Irene", "Jack",
+	"Kate", "Leo", "Mia", "Noah", "Olivia", "Paul", "Quinn", "Rose", "Sam", "Tina",
+}
+
+var lastNames = []string{
+	"Adams", "Brown", "Clark", "Davis", "Evans", "Fisher", "Green", "Harris", "Irwin", "Jones",
+	"King", "Lewis", "Miller", "Nowak", "Owens", "Parker", "Quigley", "Roberts", "Smith", "Turner",
+}
+
+// Input is the data available to a template.
+type Input struct {
+	Name      string
+	Namespace string
+	Params    map[string]string
+	// BaseTime is the reference point for date functions.
+	BaseTime time.Time
+}
+
+// Render executes jsonTemplate as a Go text/template.
+// Generators (uuid, fakeName, fakeInt) are seeded with namespace and name, so the same input always renders the same output.
+func Render(jsonTemplate string, input Input) (string, error) {
+	seed := fnv.New64a()
+	_, _ = seed.Write([]byte(input.Namespace + "/" + input.Name))
+	rnd := rand.New(rand.NewSource(int64(seed.Sum64())))
+	limits := &renderLimits{deadline: time.Now().Add(renderTimeout)}
+	tpl, err := template.New("jsonConfig").
+		Option("missingkey=error").
+		Funcs(funcMap(rnd, input.BaseTime.UTC(), limits)).
+		Parse(jsonTemplate)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot parse template")
+	}
+	for _, t := range tpl.Templates() {
+		if t.Tree != nil {
+			if err := checkRanges(t.Tree.Root); err != nil {
+				return "", errors.Wrap(err, "cannot parse template")
+			}
+		}
+	}
+	params := input.Params
+	if params == nil {
+		params = map[string]string{}
+	}
+	data := map[string]interface{}{
+		"Name":      input.Name,
+		"Namespace": input.Namespace,
+		"Params":    params,
+	}
+	out := &limitedWriter{limits: limits}
+	// loops without output or seq calls are not stopped by the limits, rendering is abandoned after renderTimeout
+	done := make(chan error, 1)
+	go func() {
+		done <- tpl.Execute(out, data)
+	}()
+	timer := time.NewTimer(renderTimeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			return "", errors.Wrap(err, "cannot render template")
+		}
+		return out.buf.String(), nil
+	case <-timer.C:
+		return "", fmt.Errorf("cannot render template: rendering takes longer than %s", renderTimeout)
+	}
+}
+
+// checkRanges allows range only over seq and fields like .Params, so the number of iterations is bounded.
+func checkRanges(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := checkRanges(child); err != nil {
+				return err
+			}
+		}
+	case *parse.IfNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.WithNode:
+		return checkBranch(&n.BranchNode)
+	case *parse.RangeNode:
+		if !boundedRange(n.Pipe) {
+			return fmt.Errorf("range over '%s' is not allowed, use seq or a field like .Params", n.Pipe)
+		}
+		return checkBranch(&n.BranchNode)
+	}
+	return nil
+}
+
+func checkBranch(n *parse.BranchNode) error {
+	if err := checkRanges(n.List); err != nil {
+		return err
+	}
+	return checkRanges(n.ElseList)
+}
+
+func boundedRange(pipe *parse.PipeNode) bool {
+	if pipe == nil || len(pipe.Cmds) != 1 || len(pipe.Cmds[0].Args) == 0 {
+		return false
+	}
+	switch arg := pipe.Cmds[0].Args[0].(type) {
+	case *parse.IdentifierNode:
+		return arg.Ident == "seq"
+	case *parse.FieldNode:
+		return true
+	case *parse.VariableNode:
+		// a field of the root data like $.Params
+		return len(arg.Ident) > 1 && arg.Ident[0] == "$"
+	}
+	return false
+}
+
+// renderLimits bounds resources used by a single rendering.
+type renderLimits struct {
+	deadline time.Time
+	seqItems int
+}
+
+func (l *renderLimits) check() error {
+	if time.Now().After(l.deadline) {
+		return fmt.Errorf("rendering takes longer than %s", renderTimeout)
+	}
+	return nil
+}
+
+// limitedWriter fails when the output exceeds maxOutputBytes or rendering exceeds its deadline, which stops the template.
+type limitedWriter struct {
+	buf    bytes.Buffer
+	limits *renderLimits
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > maxOutputBytes {
+		return 0, fmt.Errorf("rendered output exceeds %d bytes", maxOutputBytes)
+	}
+	if err := w.limits.check(); err != nil {
+		return 0, err
+	}
+	return w.buf.Write(p)
+}
+
+func funcMap(rnd *rand.Rand, baseTime time.Time, limits *renderLimits) template.FuncMap {
+	return template.FuncMap{
+		// seq returns [1..n], to be used with range
+		"seq": func(n int) ([]int, error) {
+			if n < 0 || n > maxSeqLength {
+				return nil, fmt.Errorf("seq length must be between 0 and %d", maxSeqLength)
+			}
+			if err := limits.check(); err != nil {
+				return nil, err
+			}
+			limits.seqItems += n
+			if limits.seqItems > maxSeqItems {
+				return nil, fmt.Errorf("seq calls generate more than %d items in total", maxSeqItems)
+			}
+			items := make([]int, n)
+			for i := range items {
+				items[i] = i + 1
+			}
+			return items, nil
+		},
+		"add": func(a, b int) int {
+			return a + b
+		},
+		"uuid": func() string {
+			b := make([]byte, 16)
+			_, _ = rnd.Read(b)
+			b[6] = (b[6] & 0x0f) | 0x40 // version 4
+			b[8] = (b[8] & 0x3f) | 0x80 // variant RFC 4122
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+		},
+		"fakeName": func() string {
+			return firstNames[rnd.Intn(len(firstNames))] + " " + lastNames[rnd.Intn(len(lastNames))]
+		},
+		"fakeInt": func(min, max int) (int, error) {
+			if max < min {
+				return 0, fmt.Errorf("fakeInt max (%d) is lower than min (%d)", max, min)
+			}
+			return min + rnd.Intn(max-min+1), nil
+		},
+		// date returns baseTime shifted by offset (Go duration or number of days like "-7d") in the given layout
+		"date": func(layout string, offset string) (string, error) {
+			d, err := parseOffset(offset)
+			if err != nil {
+				return "", err
+			}
+			return baseTime.Add(d).Format(layout), nil
+		},
+		"dateOffset": func(offset string) (string, error) {
+			d, err := parseOffset(offset)
+			if err != nil {
+				return "", err
+			}
+			return baseTime.Add(d).Format(time.RFC3339), nil
+		},
+		// json encodes a value as a json literal, useful for quoting parameters
+		"json": func(v interface{}) (string, error) {
+			buf, err := json.Marshal(v)
+			return string(buf), err
+		},
+	}
+}
+
+func parseOffset(offset string) (time.Duration, error) {
+	if offset == "" {
+		return 0, nil
+	}
+	if strings.HasSuffix(offset, "d") {
+		var days int
+		if _, err := fmt.Sscanf(offset, "%dd", &days); err != nil {
+			return 0, fmt.Errorf("invalid date offset '%s'", offset)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(offset)
+	if err != nil {
+		return 0, fmt.Errorf("invalid date offset '%s'", offset)
+	}
+	return d, nil
+}
@@ -0,0 +1,77 @@
+package jsontemplate
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	baseTime := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	input := Input{Name: "app-test", Namespace: "ns", Params: map[string]string{"tenant": "acme"}, BaseTime: baseTime}
+	tests := []struct {
+		name     string
+		template string
+		want     string
+		wantErr  bool
+	}{
+		{name: "plain json", template: `{"k":"value"}`, want: `{"k":"value"}`},
+		{name: "parameter", template: `{"tenant":{{ .Params.tenant | json }}}`, want: `{"tenant":"acme"}`},
+		{name: "missing parameter", template: `{"tenant":"{{ .Params.other }}"}`, wantErr: true},
+		{name: "seq", template: `[{{ range $i, $n := seq 3 }}{{ if $i }},{{ end }}{{ $n }}{{ end }}]`, want: `[1,2,3]`},
+		{name: "seq too long", template: `{{ range seq 10001 }}{{ end }}`, wantErr: true},
+		{name: "nested seq", template: `{{ range seq 10000 }}{{ range seq 10000 }}{{ end }}{{ end }}`, wantErr: true},
+		{name: "output too large", template: `{{ range seq 10000 }}{{ range seq 9 }}{{ "0123456789012345678901234567890123456789" }}{{ end }}{{ end }}`, wantErr: true},
+		{name: "date offset", template: `"{{ dateOffset "-2d" }}"`, want: `"2024-03-08T12:00:00Z"`},
+		{name: "date layout", template: `"{{ date "2006-01-02" "48h" }}"`, want: `"2024-03-12"`},
+		{name: "invalid offset", template: `"{{ dateOffset "yesterday" }}"`, wantErr: true},
+		{name: "syntax error", template: `{{ range }}`, wantErr: true},
+		{name: "range over int", template: `{{ range 300000000 }}{{ end }}`, wantErr: true},
+		{name: "range over variable", template: `{{ $n := 300000000 }}{{ range $n }}{{ end }}`, wantErr: true},
+		{name: "range in define", template: `{{ define "loop" }}{{ range 300000000 }}{{ end }}{{ end }}{}`, wantErr: true},
+		{name: "range over params", template: `[{{ range $k, $v := .Params }}{{ $k | json }}{{ end }}]`, want: `["tenant"]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(tt.template, input)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRender_deterministic(t *testing.T) {
+	tpl := `[{{ range $i, $n := seq 100 }}{{ if $i }},{{ end }}{"id":"{{ uuid }}","name":"{{ fakeName }}","age":{{ fakeInt 18 99 }}}{{ end }}]`
+	input := Input{Name: "app-test", Namespace: "ns"}
+	first, err := Render(tpl, input)
+	assert.NoError(t, err)
+	second, err := Render(tpl, input)
+	assert.NoError(t, err)
+	assert.Equal(t, first, second)
+	var items []map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(first), &items))
+	assert.Len(t, items, 100)
+
+	other, err := Render(tpl, Input{Name: "app-other", Namespace: "ns"})
+	assert.NoError(t, err)
+	assert.NotEqual(t, first, other)
+}
+
+func TestRender_timeout(t *testing.T) {
+	// a loop without output and seq calls, only the timeout stops it
+	tpl := `{{ range seq 9000 }}{{ range seq 10 }}{{ range $.Params }}{{ end }}{{ end }}{{ end }}`
+	params := make(map[string]string)
+	for i := 0; i < 10000; i++ {
+		params[strconv.Itoa(i)] = ""
+	}
+	started := time.Now()
+	_, err := Render(tpl, Input{Name: "app-test", Namespace: "ns", Params: params})
+	assert.ErrorContains(t, err, "rendering takes longer than")
+	assert.Less(t, time.Since(started), renderTimeout+time.Second)
+}
@@ -0,0 +1,108 @@
+package sidecar
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileRefreshInterval defines how often mounted credentials are re-read, kubelet updates them when a Secret changes.
+const fileRefreshInterval = 30 * time.Second
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Name  string
+	Roles []string
+}
+
+// errUnauthenticated is returned when a request has no valid credentials.
+var errUnauthenticated = fmt.Errorf("unauthenticated")
+
+type authenticator struct {
+	config *AuthConfig
+	files  *fileCache
+	jwt    *jwtVerifier
+}
+
+func newAuthenticator(config *AuthConfig) *authenticator {
+	files := newFileCache(fileRefreshInterval)
+	a := &authenticator{config: config, files: files}
+	if config.JWT != nil {
+		a.jwt = newJWTVerifier(config.JWT, files)
+	}
+	return a
+}
+
+// authenticate returns the principal of the request, nil for an accepted anonymous request.
+func (a *authenticator) authenticate(r *http.Request) (*Principal, error) {
+	authorization := r.Header.Get("Authorization")
+	switch {
+	case strings.HasPrefix(authorization, "Bearer "):
+		token := strings.TrimPrefix(authorization, "Bearer ")
+		for _, tc := range a.config.BearerTokens {
+			expected, err := a.files.read(tc.TokenFile)
+			if err == nil && expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1 {
+				return &Principal{Name: tc.Principal, Roles: tc.Roles}, nil
+			}
+		}
+		if a.jwt != nil {
+			return a.jwt.verify(token)
+		}
+		return nil, errUnauthenticated
+	case strings.HasPrefix(authorization, "Basic "):
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return nil, errUnauthenticated
+		}
+		for _, bu := range a.config.BasicUsers {
+			expectedUser, err1 := a.files.read(bu.UsernameFile)
+			expectedPassword, err2 := a.files.read(bu.PasswordFile)
+			if err1 != nil || err2 != nil || expectedUser != username {
+				continue
+			}
+			if subtle.ConstantTimeCompare([]byte(expectedPassword), []byte(password)) == 1 {
+				return &Principal{Name: username, Roles: bu.Roles}, nil
+			}
+		}
+		return nil, errUnauthenticated
+	case authorization == "" && a.config.AnonymousRead && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		return nil, nil
+	default:
+		return nil, errUnauthenticated
+	}
+}
+
+// fileCache reads small files and keeps their content for a while.
+type fileCache struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[string]fileCacheEntry
+}
+
+type fileCacheEntry struct {
+	content string
+	read    time.Time
+}
+
+func newFileCache(ttl time.Duration) *fileCache {
+	return &fileCache{ttl: ttl, entries: map[string]fileCacheEntry{}}
+}
+
+func (c *fileCache) read(path string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if entry, ok := c.entries[path]; ok && time.Since(entry.read) < c.ttl {
+		return entry.content, nil
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	content := strings.TrimSpace(string(buf))
+	c.entries[path] = fileCacheEntry{content: content, read: time.Now()}
+	return content, nil
+}
@@ -0,0 +1,92 @@
+package sidecar
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, dir string, name string, content string) string {
+	path := filepath.Join(dir, name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func signedJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	h := crypto.SHA256.New()
+	h.Write([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h.Sum(nil))
+	assert.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthenticator(t *testing.T) {
+	dir := t.TempDir()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"k1","n":"%s","e":"%s"}]}`,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()), base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()))
+	auth := newAuthenticator(&AuthConfig{
+		BearerTokens: []TokenConfig{{TokenFile: writeFile(t, dir, "token", "secret-token\n"), Principal: "ci", Roles: []string{"writer"}}},
+		BasicUsers:   []BasicUserConfig{{UsernameFile: writeFile(t, dir, "username", "john"), PasswordFile: writeFile(t, dir, "password", "pass")}},
+		JWT:          &JWTConfig{JWKSFile: writeFile(t, dir, "jwks.json", jwks), Issuer: "https://issuer", Audiences: []string{"mocks"}},
+	})
+	validClaims := map[string]interface{}{"sub": "partner", "iss": "https://issuer", "aud": "mocks", "exp": time.Now().Add(time.Hour).Unix(), "roles": []string{"reader"}}
+	expiredClaims := map[string]interface{}{"sub": "partner", "iss": "https://issuer", "aud": "mocks", "exp": time.Now().Add(-time.Hour).Unix()}
+	otherAudienceClaims := map[string]interface{}{"sub": "partner", "iss": "https://issuer", "aud": "other", "exp": time.Now().Add(time.Hour).Unix()}
+	noExpirationClaims := map[string]interface{}{"sub": "partner", "iss": "https://issuer", "aud": "mocks"}
+	tests := []struct {
+		name          string
+		authorization string
+		wantPrincipal string
+		wantErr       bool
+	}{
+		{name: "no credentials", wantErr: true},
+		{name: "valid token", authorization: "Bearer secret-token", wantPrincipal: "ci"},
+		{name: "invalid token", authorization: "Bearer other", wantErr: true},
+		{name: "valid basic auth", authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("john:pass")), wantPrincipal: "john"},
+		{name: "invalid password", authorization: "Basic " + base64.StdEncoding.EncodeToString([]byte("john:x")), wantErr: true},
+		{name: "valid jwt", authorization: "Bearer " + signedJWT(t, key, "k1", validClaims), wantPrincipal: "partner"},
+		{name: "expired jwt", authorization: "Bearer " + signedJWT(t, key, "k1", expiredClaims), wantErr: true},
+		{name: "jwt without expiration", authorization: "Bearer " + signedJWT(t, key, "k1", noExpirationClaims), wantErr: true},
+		{name: "jwt for other audience", authorization: "Bearer " + signedJWT(t, key, "k1", otherAudienceClaims), wantErr: true},
+		{name: "jwt with unknown key", authorization: "Bearer " + signedJWT(t, key, "k2", validClaims), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/people", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			principal, err := auth.authenticate(req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantPrincipal, principal.Name)
+		})
+	}
+}
+
+func TestAuthenticator_anonymousRead(t *testing.T) {
+	auth := newAuthenticator(&AuthConfig{AnonymousRead: true})
+	principal, err := auth.authenticate(httptest.NewRequest("GET", "/people", nil))
+	assert.NoError(t, err)
+	assert.Nil(t, principal)
+	_, err = auth.authenticate(httptest.NewRequest("DELETE", "/people/1", nil))
+	assert.Error(t, err)
+}
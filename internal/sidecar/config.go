@@ -0,0 +1,130 @@
+package sidecar
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+)
+
+// Config is the configuration of the sidecar, generated by the operator and stored in the ConfigMap of JsonServer.
+type Config struct {
+	// ListenAddress of the proxy in front of json-server
+	ListenAddress string `json:"listenAddress"`
+	// AdminAddress serves /stats, /healthz and /db
+	AdminAddress string `json:"adminAddress"`
+	// AdminTokenFile contains the bearer token required by /db, the database is not served without it
+	AdminTokenFile string `json:"adminTokenFile,omitempty"`
+	// Upstream is the url of json-server
+	Upstream string      `json:"upstream"`
+	Auth     *AuthConfig `json:"auth,omitempty"`
+	// AccessRules restrict methods allowed on paths
+	AccessRules []AccessRuleConfig `json:"accessRules,omitempty"`
+	// TLS makes the proxy serve HTTPS
+	TLS *TLSConfig `json:"tls,omitempty"`
+	// CORS policy, CORS headers of json-server are replaced when set
+	CORS *CORSConfig `json:"cors,omitempty"`
+	// Headers added to responses
+	Headers []RouteHeadersConfig `json:"headers,omitempty"`
+	// Metrics of requests are served on a separate address when set
+	Metrics *MetricsConfig `json:"metrics,omitempty"`
+	// Logging writes access logs to stdout when set
+	Logging *LoggingConfig `json:"logging,omitempty"`
+}
+
+// LoggingConfig defines access logs of proxied requests.
+type LoggingConfig struct {
+	// Format is json or text
+	Format string `json:"format"`
+	// SamplePercent of requests is logged, requests failed with 5xx status are always logged
+	SamplePercent int `json:"samplePercent"`
+	// MaxBodyBytes of request and response bodies are logged, bodies are not logged if 0
+	MaxBodyBytes int `json:"maxBodyBytes,omitempty"`
+	// RedactFields are names of JSON fields and query parameters with values replaced by [REDACTED]
+	RedactFields []string `json:"redactFields,omitempty"`
+}
+
+// MetricsConfig defines the address of /metrics in Prometheus format.
+type MetricsConfig struct {
+	Address string `json:"address"`
+	// Collections of json-server, requests to other paths are counted as "other"
+	Collections []string `json:"collections,omitempty"`
+}
+
+type CORSConfig struct {
+	// AllowedOrigins are origins like https://example.com or * for any
+	AllowedOrigins []string `json:"allowedOrigins"`
+	AllowedMethods []string `json:"allowedMethods,omitempty"`
+	// AllowedHeaders are headers allowed in requests, all requested headers if empty
+	AllowedHeaders   []string `json:"allowedHeaders,omitempty"`
+	ExposedHeaders   []string `json:"exposedHeaders,omitempty"`
+	AllowCredentials bool     `json:"allowCredentials,omitempty"`
+	MaxAgeSeconds    int      `json:"maxAgeSeconds,omitempty"`
+}
+
+// RouteHeadersConfig are headers of responses to requests with paths matching Pattern (or paths below them), all paths if empty.
+type RouteHeadersConfig struct {
+	Pattern string            `json:"pattern,omitempty"`
+	Headers map[string]string `json:"headers"`
+}
+
+// TLSConfig defines the certificate served by the proxy, files are re-read so a renewed certificate is used without a restart.
+type TLSConfig struct {
+	CertFile string `json:"certFile"`
+	KeyFile  string `json:"keyFile"`
+}
+
+// AuthConfig defines how requests are authenticated, a request is accepted if any of the methods accepts it.
+type AuthConfig struct {
+	BearerTokens []TokenConfig     `json:"bearerTokens,omitempty"`
+	BasicUsers   []BasicUserConfig `json:"basicUsers,omitempty"`
+	JWT          *JWTConfig        `json:"jwt,omitempty"`
+	// AnonymousRead accepts GET and HEAD requests without credentials
+	AnonymousRead bool `json:"anonymousRead,omitempty"`
+}
+
+type TokenConfig struct {
+	// TokenFile contains the token
+	TokenFile string   `json:"tokenFile"`
+	Principal string   `json:"principal"`
+	Roles     []string `json:"roles,omitempty"`
+}
+
+type BasicUserConfig struct {
+	// UsernameFile and PasswordFile contain credentials (keys of a kubernetes.io/basic-auth Secret)
+	UsernameFile string   `json:"usernameFile"`
+	PasswordFile string   `json:"passwordFile"`
+	Roles        []string `json:"roles,omitempty"`
+}
+
+type JWTConfig struct {
+	// JWKSFile contains JSON Web Key Set used to verify signatures
+	JWKSFile  string   `json:"jwksFile"`
+	Issuer    string   `json:"issuer,omitempty"`
+	Audiences []string `json:"audiences,omitempty"`
+	// RolesClaim is the name of a claim with a list of roles
+	RolesClaim string `json:"rolesClaim,omitempty"`
+}
+
+// AccessRuleConfig allows methods on paths matching Pattern (or paths below them) to matching principals.
+type AccessRuleConfig struct {
+	// Pattern has path.Match syntax
+	Pattern string `json:"pattern"`
+	// Methods allowed, all if empty
+	Methods []string `json:"methods,omitempty"`
+	// Principals and Roles the rule applies to, all callers if both are empty
+	Principals []string `json:"principals,omitempty"`
+	Roles      []string `json:"roles,omitempty"`
+}
+
+// LoadConfig reads Config from a json file.
+func LoadConfig(path string) (*Config, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read config %s", path)
+	}
+	config := &Config{}
+	if err := json.Unmarshal(buf, config); err != nil {
+		return nil, errors.Wrapf(err, "cannot parse config %s", path)
+	}
+	return config, nil
+}
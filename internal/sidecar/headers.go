@@ -0,0 +1,96 @@
+package sidecar
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultCORSMethods are allowed by preflight responses when CORSConfig has no methods
+var defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+type corsPolicy struct {
+	config *CORSConfig
+}
+
+func newCORSPolicy(config *CORSConfig) *corsPolicy {
+	return &corsPolicy{config: config}
+}
+
+// handle adds CORS headers to the response, it returns true if the request was a preflight request and has been answered.
+func (c *corsPolicy) handle(w http.ResponseWriter, r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if origin == "" || !c.originAllowed(origin) {
+		return false
+	}
+	if c.config.AllowCredentials || !c.anyOrigin() {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	} else {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+	}
+	if c.config.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		if len(c.config.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.config.ExposedHeaders, ", "))
+		}
+		return false
+	}
+	methods := c.config.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	if len(c.config.AllowedHeaders) > 0 {
+		w.Header().Set("Access-Control-Allow-Headers", strings.Join(c.config.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+		// all headers are allowed if none is configured
+		w.Header().Set("Access-Control-Allow-Headers", requested)
+	}
+	if c.config.MaxAgeSeconds > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(c.config.MaxAgeSeconds))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+func (c *corsPolicy) anyOrigin() bool {
+	for _, o := range c.config.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *corsPolicy) originAllowed(origin string) bool {
+	for _, o := range c.config.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRouteHeaders sets headers of all routes matching the path, later routes override earlier ones.
+func setRouteHeaders(header http.Header, routes []RouteHeadersConfig, requestPath string) {
+	for _, route := range routes {
+		if route.Pattern != "" && !matchesPath(route.Pattern, requestPath) {
+			continue
+		}
+		for name, value := range route.Headers {
+			header.Set(name, value)
+		}
+	}
+}
+
+// removeCORSHeaders removes CORS headers set by json-server, they are set by the sidecar.
+func removeCORSHeaders(header http.Header) {
+	for name := range header {
+		if strings.HasPrefix(name, "Access-Control-") {
+			header.Del(name)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package sidecar
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Handler_headers(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// json-server allows any origin by default
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Cache-Control", "no-cache")
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer upstream.Close()
+	server, err := NewServer(&Config{
+		Upstream: upstream.URL,
+		Auth:     &AuthConfig{AnonymousRead: true},
+		CORS:     &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true, MaxAgeSeconds: 60},
+		Headers: []RouteHeadersConfig{
+			{Headers: map[string]string{"Cache-Control": "max-age=10", "X-Mock": "true"}},
+			{Pattern: "/posts", Headers: map[string]string{"Cache-Control": "no-store"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	t.Run("preflight without credentials", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/posts", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		req.Header.Set("Access-Control-Request-Headers", "Authorization")
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "Authorization", rec.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "60", rec.Header().Get("Access-Control-Max-Age"))
+	})
+	t.Run("allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/posts/1", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, []string{"https://app.example.com"}, rec.Header().Values("Access-Control-Allow-Origin"))
+		assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+		assert.Equal(t, "true", rec.Header().Get("X-Mock"))
+	})
+	t.Run("other origin", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/comments", nil)
+		req.Header.Set("Origin", "https://other.example.com")
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "max-age=10", rec.Header().Get("Cache-Control"))
+	})
+}
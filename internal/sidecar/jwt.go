@@ -0,0 +1,208 @@
+package sidecar
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// jwtVerifier verifies RS* and ES* signed tokens with keys from a JWKS file.
+type jwtVerifier struct {
+	config *JWTConfig
+	files  *fileCache
+	now    func() time.Time
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func newJWTVerifier(config *JWTConfig, files *fileCache) *jwtVerifier {
+	return &jwtVerifier{config: config, files: files, now: time.Now}
+}
+
+func (v *jwtVerifier) verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errUnauthenticated
+	}
+	header := jwtHeader{}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errUnauthenticated
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errUnauthenticated
+	}
+	keys, err := v.keys()
+	if err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, key := range keys {
+		if header.Kid != "" && key.Kid != header.Kid {
+			continue
+		}
+		if verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errUnauthenticated
+	}
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errUnauthenticated
+	}
+	if err := v.validateClaims(claims); err != nil {
+		return nil, err
+	}
+	principal := &Principal{}
+	principal.Name, _ = claims["sub"].(string)
+	rolesClaim := v.config.RolesClaim
+	if rolesClaim == "" {
+		rolesClaim = "roles"
+	}
+	if roles, ok := claims[rolesClaim].([]interface{}); ok {
+		for _, role := range roles {
+			if s, ok := role.(string); ok {
+				principal.Roles = append(principal.Roles, s)
+			}
+		}
+	}
+	return principal, nil
+}
+
+// validateClaims rejects tokens without exp, they would never expire.
+func (v *jwtVerifier) validateClaims(claims map[string]interface{}) error {
+	now := v.now().Unix()
+	if exp, ok := claims["exp"].(float64); !ok || now >= int64(exp) {
+		return errUnauthenticated
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < int64(nbf) {
+		return errUnauthenticated
+	}
+	if v.config.Issuer != "" && claims["iss"] != v.config.Issuer {
+		return errUnauthenticated
+	}
+	if len(v.config.Audiences) > 0 {
+		audiences := make([]string, 0)
+		switch aud := claims["aud"].(type) {
+		case string:
+			audiences = append(audiences, aud)
+		case []interface{}:
+			for _, a := range aud {
+				if s, ok := a.(string); ok {
+					audiences = append(audiences, s)
+				}
+			}
+		}
+		for _, expected := range v.config.Audiences {
+			for _, aud := range audiences {
+				if aud == expected {
+					return nil
+				}
+			}
+		}
+		return errUnauthenticated
+	}
+	return nil
+}
+
+func (v *jwtVerifier) keys() ([]jwk, error) {
+	content, err := v.files.read(v.config.JWKSFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read JWKS: %w", err)
+	}
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	if err := json.Unmarshal([]byte(content), &set); err != nil {
+		return nil, fmt.Errorf("cannot parse JWKS: %w", err)
+	}
+	return set.Keys, nil
+}
+
+func verifySignature(alg string, key jwk, signed []byte, signature []byte) bool {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "ES512":
+		hash = crypto.SHA512
+	default:
+		return false
+	}
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+	switch {
+	case strings.HasPrefix(alg, "RS") && key.Kty == "RSA":
+		n, err1 := decodeBigInt(key.N)
+		e, err2 := decodeBigInt(key.E)
+		if err1 != nil || err2 != nil {
+			return false
+		}
+		publicKey := &rsa.PublicKey{N: n, E: int(e.Int64())}
+		return rsa.VerifyPKCS1v15(publicKey, hash, digest, signature) == nil
+	case strings.HasPrefix(alg, "ES") && key.Kty == "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return false
+		}
+		x, err1 := decodeBigInt(key.X)
+		y, err2 := decodeBigInt(key.Y)
+		if err1 != nil || err2 != nil || len(signature)%2 != 0 {
+			return false
+		}
+		publicKey := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		r := new(big.Int).SetBytes(signature[:len(signature)/2])
+		s := new(big.Int).SetBytes(signature[len(signature)/2:])
+		return ecdsa.Verify(publicKey, digest, r, s)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	buf, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(buf), nil
+}
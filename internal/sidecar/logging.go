@@ -0,0 +1,171 @@
+package sidecar
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// accessLogger writes a line per sampled request in json or text format.
+type accessLogger struct {
+	config *LoggingConfig
+	redact map[string]bool
+	// sample reports if a request is logged, replaced in tests
+	sample func() bool
+	mu     sync.Mutex
+	out    io.Writer
+}
+
+func newAccessLogger(config *LoggingConfig, out io.Writer) *accessLogger {
+	l := &accessLogger{config: config, redact: map[string]bool{}, out: out}
+	for _, field := range config.RedactFields {
+		l.redact[strings.ToLower(field)] = true
+	}
+	l.sample = func() bool {
+		return config.SamplePercent >= 100 || rand.Intn(100) < config.SamplePercent
+	}
+	return l
+}
+
+// field is a key and value of a log line, kept in order for the text format
+type field struct {
+	key   string
+	value interface{}
+}
+
+// instrument logs requests handled by next.
+func (l *accessLogger) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		var requestBody []byte
+		if l.config.MaxBodyBytes > 0 && r.Body != nil {
+			requestBody, _ = io.ReadAll(io.LimitReader(r.Body, int64(l.config.MaxBodyBytes)+1))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(requestBody), r.Body), r.Body}
+		}
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK, bodyLimit: l.config.MaxBodyBytes}
+		next.ServeHTTP(recorder, r)
+		if recorder.status < http.StatusInternalServerError && !l.sample() {
+			return
+		}
+		fields := []field{
+			{"time", start.UTC().Format(time.RFC3339Nano)},
+			{"method", r.Method},
+			{"path", r.URL.Path},
+		}
+		if r.URL.RawQuery != "" {
+			fields = append(fields, field{"query", l.redactQuery(r.URL.Query())})
+		}
+		fields = append(fields,
+			field{"status", recorder.status},
+			field{"durationMs", float64(time.Since(start).Microseconds()) / 1000},
+			field{"bytes", recorder.size},
+			field{"remoteAddr", r.RemoteAddr},
+		)
+		if l.config.MaxBodyBytes > 0 {
+			if len(requestBody) > 0 {
+				fields = append(fields, field{"requestBody", l.body(requestBody)})
+			}
+			if recorder.body.Len() > 0 {
+				fields = append(fields, field{"responseBody", l.body(recorder.body.Bytes())})
+			}
+		}
+		l.write(fields)
+	})
+}
+
+// body returns a logged body cut to MaxBodyBytes, values of redacted fields are replaced in JSON bodies.
+// A cut JSON body cannot be redacted, so it is not logged when fields are redacted.
+func (l *accessLogger) body(body []byte) string {
+	truncated := len(body) > l.config.MaxBodyBytes
+	if truncated {
+		body = body[:l.config.MaxBodyBytes]
+	}
+	if len(l.redact) > 0 {
+		var value interface{}
+		if err := json.Unmarshal(body, &value); err != nil {
+			if truncated {
+				return fmt.Sprintf("[TRUNCATED at %d bytes, not logged because of redacted fields]", l.config.MaxBodyBytes)
+			}
+			return string(body)
+		}
+		redactedBody, _ := json.Marshal(l.redactValue(value))
+		return string(redactedBody)
+	}
+	if truncated {
+		return string(body) + "...[TRUNCATED]"
+	}
+	return string(body)
+}
+
+func (l *accessLogger) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if l.redact[strings.ToLower(key)] {
+				v[key] = redacted
+			} else {
+				v[key] = l.redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = l.redactValue(item)
+		}
+	}
+	return value
+}
+
+func (l *accessLogger) redactQuery(query url.Values) string {
+	for key := range query {
+		if l.redact[strings.ToLower(key)] {
+			query[key] = []string{redacted}
+		}
+	}
+	return query.Encode()
+}
+
+func (l *accessLogger) write(fields []field) {
+	var line []byte
+	if l.config.Format == "text" {
+		parts := make([]string, 0, len(fields))
+		for _, f := range fields {
+			value := fmt.Sprint(f.value)
+			if value == "" || strings.ContainsAny(value, " \t\n\"=") {
+				value = strconv.Quote(value)
+			}
+			parts = append(parts, f.key+"="+value)
+		}
+		line = []byte(strings.Join(parts, " "))
+	} else {
+		// fields are written in order, a map would sort them
+		buf := bytes.NewBufferString("{")
+		for i, f := range fields {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			key, _ := json.Marshal(f.key)
+			value, _ := json.Marshal(f.value)
+			buf.Write(key)
+			buf.WriteString(":")
+			buf.Write(value)
+		}
+		buf.WriteString("}")
+		line = buf.Bytes()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.out.Write(append(line, '\n'))
+}
@@ -0,0 +1,77 @@
+package sidecar
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAccessLogger_instrument(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			http.Error(w, "failed", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"id":1,"name":"john","password":"secret"}`))
+	})
+	out := &bytes.Buffer{}
+	logger := newAccessLogger(&LoggingConfig{Format: "json", SamplePercent: 100, MaxBodyBytes: 1024, RedactFields: []string{"Password", "token"}}, out)
+
+	rec := httptest.NewRecorder()
+	logger.instrument(handler).ServeHTTP(rec, httptest.NewRequest("POST", "/people?token=abc&x=1", strings.NewReader(`{"name":"john","password":"secret"}`)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `{"id":1,"name":"john","password":"secret"}`, rec.Body.String(), "response is not changed")
+	entry := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "POST", entry["method"])
+	assert.Equal(t, "/people", entry["path"])
+	assert.Equal(t, "token=%5BREDACTED%5D&x=1", entry["query"])
+	assert.Equal(t, float64(201), entry["status"])
+	assert.Equal(t, `{"name":"john","password":"[REDACTED]"}`, entry["requestBody"])
+	assert.Equal(t, `{"id":1,"name":"john","password":"[REDACTED]"}`, entry["responseBody"])
+
+	// requests not sampled are not logged, unless they fail
+	out.Reset()
+	logger.sample = func() bool { return false }
+	logger.instrument(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/people", nil))
+	assert.Empty(t, out.String())
+	logger.instrument(handler).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/fail", nil))
+	assert.Contains(t, out.String(), `"status":500`)
+}
+
+func TestAccessLogger_text(t *testing.T) {
+	out := &bytes.Buffer{}
+	logger := newAccessLogger(&LoggingConfig{Format: "text", SamplePercent: 100}, out)
+	logger.instrument(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/cars/1", nil))
+	line := out.String()
+	assert.True(t, strings.HasPrefix(line, "time="), line)
+	assert.Contains(t, line, " method=GET path=/cars/1 status=404 ")
+	assert.NotContains(t, line, "responseBody", "bodies are not logged by default")
+}
+
+func TestAccessLogger_body(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxBytes int
+		redact   []string
+		body     string
+		want     string
+	}{
+		{name: "short", maxBytes: 10, body: `{"a":1}`, want: `{"a":1}`},
+		{name: "truncated", maxBytes: 10, body: `{"a":"0123456789"}`, want: `{"a":"0123...[TRUNCATED]`},
+		{name: "redacted", maxBytes: 100, redact: []string{"a"}, body: `[{"a":1,"b":{"A":2}}]`, want: `[{"a":"[REDACTED]","b":{"A":"[REDACTED]"}}]`},
+		{name: "truncated and redacted", maxBytes: 10, redact: []string{"a"}, body: `{"a":"0123456789"}`, want: "[TRUNCATED at 10 bytes, not logged because of redacted fields]"},
+		{name: "not json", maxBytes: 10, redact: []string{"a"}, body: `a=1`, want: `a=1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := newAccessLogger(&LoggingConfig{MaxBodyBytes: tt.maxBytes, RedactFields: tt.redact}, &bytes.Buffer{})
+			assert.Equal(t, tt.want, logger.body([]byte(tt.body)))
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package sidecar
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otherLabel replaces unknown collections and methods, so requests to arbitrary paths do not create new series
+const otherLabel = "other"
+
+var knownMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// requestMetrics counts proxied requests per collection, method and status in Prometheus format.
+type requestMetrics struct {
+	registry    *prometheus.Registry
+	requests    *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	collections map[string]bool
+}
+
+func newRequestMetrics(config *MetricsConfig) *requestMetrics {
+	m := &requestMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "jsonserver_http_requests_total",
+			Help: "Requests to json-server by collection, method and status code",
+		}, []string{"collection", "method", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "jsonserver_http_request_duration_seconds",
+			Help:    "Latency of requests to json-server by collection and method",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"collection", "method"}),
+		collections: map[string]bool{},
+	}
+	for _, collection := range config.Collections {
+		m.collections[collection] = true
+	}
+	m.registry.MustRegister(m.requests, m.duration, collectors.NewGoCollector(), collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+	return m
+}
+
+// instrument records requests handled by next.
+func (m *requestMetrics) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		collection, method := m.collection(r.URL.Path), m.method(r.Method)
+		m.requests.WithLabelValues(collection, method, strconv.Itoa(recorder.status)).Inc()
+		m.duration.WithLabelValues(collection, method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// collection returns the collection of a path like /posts/1/comments, "db" for the whole database and "other" for unknown paths.
+func (m *requestMetrics) collection(path string) string {
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	switch {
+	case m.collections[segment]:
+		return segment
+	case segment == "db":
+		return segment
+	default:
+		return otherLabel
+	}
+}
+
+func (m *requestMetrics) method(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return otherLabel
+}
+
+func (m *requestMetrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
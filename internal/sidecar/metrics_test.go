@@ -0,0 +1,52 @@
+package sidecar
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_MetricsHandler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer upstream.Close()
+	server, err := NewServer(&Config{Upstream: upstream.URL})
+	assert.NoError(t, err)
+	assert.Nil(t, server.MetricsHandler(), "metrics are disabled")
+
+	server, err = NewServer(&Config{Upstream: upstream.URL, Metrics: &MetricsConfig{Address: ":9091", Collections: []string{"people"}}})
+	assert.NoError(t, err)
+	for _, path := range []string{"/people", "/people/1", "/missing"} {
+		server.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	server.Handler().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PROPFIND", "/people", nil))
+
+	rec := httptest.NewRecorder()
+	server.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	assert.Contains(t, body, `jsonserver_http_requests_total{code="200",collection="people",method="GET"} 2`)
+	assert.Contains(t, body, `jsonserver_http_requests_total{code="404",collection="other",method="GET"} 1`)
+	assert.Contains(t, body, `jsonserver_http_requests_total{code="200",collection="people",method="other"} 1`)
+	assert.Contains(t, body, `jsonserver_http_request_duration_seconds_count{collection="people",method="GET"} 2`)
+}
+
+func Test_requestMetrics_collection(t *testing.T) {
+	m := newRequestMetrics(&MetricsConfig{Collections: []string{"people", "db"}})
+	tests := map[string]string{
+		"/people":            "people",
+		"/people/1/comments": "people",
+		"/db":                "db",
+		"/":                  "other",
+		"/cars":              "other",
+	}
+	for path, want := range tests {
+		assert.Equal(t, want, m.collection(path), path)
+	}
+}
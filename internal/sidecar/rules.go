@@ -0,0 +1,132 @@
+package sidecar
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// authorizer enforces access rules, a request to a path matched by any rule needs a rule that allows it.
+type authorizer struct {
+	rules []AccessRuleConfig
+}
+
+func newAuthorizer(rules []AccessRuleConfig) *authorizer {
+	return &authorizer{rules: rules}
+}
+
+// authorize reports if the principal (nil when anonymous) can send the request.
+// A nested route of json-server like /posts/1/comments reads and writes comments, so it needs access to both paths.
+// Collections included by _embed and _expand are read, so they need GET access.
+func (a *authorizer) authorize(r *http.Request, principal *Principal) bool {
+	for _, requestPath := range routedPaths(r.URL.Path) {
+		if !a.authorizePath(requestPath, r.Method, principal) {
+			return false
+		}
+	}
+	for _, relatedPath := range relatedPaths(r.URL.Query()) {
+		if !a.authorizePath(relatedPath, http.MethodGet, principal) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a *authorizer) authorizePath(requestPath string, method string, principal *Principal) bool {
+	restricted := false
+	for _, rule := range a.rules {
+		if !matchesPath(rule.Pattern, requestPath) {
+			continue
+		}
+		restricted = true
+		if appliesTo(rule, principal) && allowsMethod(rule, method) {
+			return true
+		}
+	}
+	return !restricted
+}
+
+// routedPaths returns the path and, for nested routes /<parent>/<id>/<collection>, the path of the nested collection.
+func routedPaths(requestPath string) []string {
+	p := path.Clean("/" + requestPath)
+	paths := []string{p}
+	if segments := strings.Split(strings.TrimPrefix(p, "/"), "/"); len(segments) > 2 {
+		paths = append(paths, "/"+strings.Join(segments[2:], "/"))
+	}
+	return paths
+}
+
+// relatedPaths returns paths of collections json-server includes in the response for _embed and _expand parameters.
+// Parameters may be lists (_embed=a,b or _embed[]=a) and _expand names the singular of a collection, so plural forms are returned too.
+func relatedPaths(query url.Values) []string {
+	paths := make([]string, 0)
+	for key, values := range query {
+		if name, _, _ := strings.Cut(key, "["); name != "_embed" && name != "_expand" {
+			continue
+		}
+		for _, value := range values {
+			for _, collection := range strings.Split(value, ",") {
+				collection = strings.TrimSpace(collection)
+				if collection == "" {
+					continue
+				}
+				paths = append(paths, "/"+collection, "/"+collection+"s", "/"+collection+"es")
+				if strings.HasSuffix(collection, "y") {
+					paths = append(paths, "/"+strings.TrimSuffix(collection, "y")+"ies")
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// matchesPath reports if the pattern matches the path or one of its parents, so /posts matches /posts/1 too.
+// Paths are routed by json-server regardless of case, so they are compared in lower case.
+func matchesPath(pattern string, requestPath string) bool {
+	pattern = strings.ToLower(pattern)
+	p := strings.ToLower(path.Clean("/" + requestPath))
+	for {
+		if matched, _ := path.Match(pattern, p); matched {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+		p = path.Dir(p)
+	}
+}
+
+func appliesTo(rule AccessRuleConfig, principal *Principal) bool {
+	if len(rule.Principals) == 0 && len(rule.Roles) == 0 {
+		return true
+	}
+	if principal == nil {
+		return false
+	}
+	for _, name := range rule.Principals {
+		if name == principal.Name {
+			return true
+		}
+	}
+	for _, role := range rule.Roles {
+		for _, principalRole := range principal.Roles {
+			if role == principalRole {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func allowsMethod(rule AccessRuleConfig, method string) bool {
+	if len(rule.Methods) == 0 {
+		return true
+	}
+	for _, m := range rule.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
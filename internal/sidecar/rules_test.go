@@ -0,0 +1,59 @@
+package sidecar
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizer(t *testing.T) {
+	authz := newAuthorizer([]AccessRuleConfig{
+		{Pattern: "/posts", Methods: []string{"GET", "HEAD"}},
+		{Pattern: "/posts", Roles: []string{"writer"}},
+		{Pattern: "/comments/*", Principals: []string{"ci"}, Methods: []string{"DELETE"}},
+		{Pattern: "/secrets", Roles: []string{"admin"}},
+	})
+	writer := &Principal{Name: "john", Roles: []string{"writer"}}
+	ci := &Principal{Name: "ci"}
+	tests := []struct {
+		method    string
+		path      string
+		principal *Principal
+		want      bool
+	}{
+		{method: "GET", path: "/posts", want: true},
+		{method: "GET", path: "/posts/1", want: true},
+		{method: "POST", path: "/posts", want: false},
+		{method: "POST", path: "/posts", principal: ci, want: false},
+		{method: "POST", path: "/posts", principal: writer, want: true},
+		{method: "DELETE", path: "/posts/1", principal: writer, want: true},
+		{method: "DELETE", path: "/comments/1", principal: writer, want: false},
+		{method: "DELETE", path: "/comments/1", principal: ci, want: true},
+		{method: "DELETE", path: "/comments", want: true},
+		{method: "POST", path: "/profile", want: true},
+		{method: "POST", path: "/posts/../profile", want: true},
+		{method: "POST", path: "/POSTS", want: false},
+		{method: "GET", path: "/Secrets/1", want: false},
+		{method: "GET", path: "/profile/1/secrets", want: false},
+		{method: "POST", path: "/posts/1/comments", want: false},
+		{method: "POST", path: "/posts/1/comments", principal: writer, want: true},
+		{method: "DELETE", path: "/posts/1/comments/2", principal: writer, want: false},
+		{method: "GET", path: "/posts?_embed=secrets", want: false},
+		{method: "GET", path: "/posts?_embed=secrets", principal: &Principal{Name: "root", Roles: []string{"admin"}}, want: true},
+		{method: "GET", path: "/posts/1?_expand=secret", want: false},
+		{method: "GET", path: "/posts?_embed=comments,secrets", want: false},
+		{method: "GET", path: "/posts?_embed[]=secrets", want: false},
+		{method: "GET", path: "/posts?_embed=comments&_expand=profile", want: true},
+		{method: "GET", path: "/profile?_embed=posts", want: true},
+	}
+	for _, tt := range tests {
+		name := tt.method + " " + tt.path
+		if tt.principal != nil {
+			name += " as " + tt.principal.Name
+		}
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "http://localhost"+tt.path, nil)
+			assert.Equal(t, tt.want, authz.authorize(req, tt.principal))
+		})
+	}
+}
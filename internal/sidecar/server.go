@@ -0,0 +1,251 @@
+package sidecar
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Stats are counters of a sidecar since it has started, served on the admin address.
+type Stats struct {
+	StartTime time.Time `json:"startTime"`
+	// LastRequestTime is StartTime if there were no requests
+	LastRequestTime         time.Time `json:"lastRequestTime"`
+	Requests                int64     `json:"requests"`
+	UnauthenticatedRequests int64     `json:"unauthenticatedRequests"`
+}
+
+// Server is a reverse proxy in front of json-server.
+type Server struct {
+	config          *Config
+	upstream        *url.URL
+	proxy           *httputil.ReverseProxy
+	auth            *authenticator
+	authz           *authorizer
+	certificates    *certificateLoader
+	cors            *corsPolicy
+	metrics         *requestMetrics
+	accessLog       *accessLogger
+	files           *fileCache
+	startTime       time.Time
+	lastRequest     atomic.Int64
+	requests        atomic.Int64
+	unauthenticated atomic.Int64
+}
+
+type principalKey struct{}
+
+func NewServer(config *Config) (*Server, error) {
+	upstream, err := url.Parse(config.Upstream)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid upstream %s", config.Upstream)
+	}
+	s := &Server{
+		config:    config,
+		upstream:  upstream,
+		proxy:     httputil.NewSingleHostReverseProxy(upstream),
+		startTime: time.Now(),
+		files:     newFileCache(fileRefreshInterval),
+	}
+	s.lastRequest.Store(s.startTime.UnixNano())
+	if config.Auth != nil {
+		s.auth = newAuthenticator(config.Auth)
+	}
+	if len(config.AccessRules) > 0 {
+		s.authz = newAuthorizer(config.AccessRules)
+	}
+	if config.CORS != nil {
+		s.cors = newCORSPolicy(config.CORS)
+	}
+	if config.Metrics != nil {
+		s.metrics = newRequestMetrics(config.Metrics)
+	}
+	if config.Logging != nil {
+		s.accessLog = newAccessLogger(config.Logging, os.Stdout)
+	}
+	s.proxy.ModifyResponse = s.modifyResponse
+	if config.TLS != nil {
+		s.certificates = newCertificateLoader(config.TLS, fileRefreshInterval)
+		if _, err := s.certificates.getCertificate(nil); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Handler returns the handler of proxied requests.
+func (s *Server) Handler() http.Handler {
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.lastRequest.Store(time.Now().UnixNano())
+		// preflight requests are answered before authentication, browsers send them without credentials
+		if s.cors != nil && s.cors.handle(w, r) {
+			return
+		}
+		if s.auth != nil {
+			principal, err := s.auth.authenticate(r)
+			if err != nil {
+				s.unauthenticated.Add(1)
+				w.Header().Set("WWW-Authenticate", `Bearer realm="json-server", Basic realm="json-server"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			r = r.WithContext(context.WithValue(r.Context(), principalKey{}, principal))
+		}
+		if s.authz != nil && !s.authz.authorize(r, PrincipalFromRequest(r)) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		s.proxy.ServeHTTP(w, r)
+	})
+	if s.metrics != nil {
+		handler = s.metrics.instrument(handler)
+	}
+	if s.accessLog != nil {
+		handler = s.accessLog.instrument(handler)
+	}
+	return handler
+}
+
+// MetricsHandler returns the handler of /metrics, nil if metrics are not enabled.
+func (s *Server) MetricsHandler() http.Handler {
+	if s.metrics == nil {
+		return nil
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metrics.handler())
+	return mux
+}
+
+func (s *Server) modifyResponse(resp *http.Response) error {
+	if s.cors != nil {
+		removeCORSHeaders(resp.Header)
+	}
+	setRouteHeaders(resp.Header, s.config.Headers, resp.Request.URL.Path)
+	return nil
+}
+
+// TLSConfig returns the TLS configuration of the proxy, nil if it serves plain HTTP.
+func (s *Server) TLSConfig() *tls.Config {
+	if s.certificates == nil {
+		return nil
+	}
+	return &tls.Config{MinVersion: tls.VersionTLS12, GetCertificate: s.certificates.getCertificate}
+}
+
+// AdminHandler returns the handler of /stats, /healthz and /db endpoints.
+func (s *Server) AdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(s.Stats())
+	})
+	// healthz checks that json-server responds, it is used by probes as the proxy requires credentials
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, http.MethodHead, s.upstream.String(), nil)
+		if err == nil {
+			var resp *http.Response
+			if resp, err = http.DefaultClient.Do(req); err == nil {
+				_ = resp.Body.Close()
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	})
+	// db returns the whole database of json-server to the operator, which keeps a snapshot of it
+	mux.HandleFunc("/db", func(w http.ResponseWriter, r *http.Request) {
+		if !s.adminAuthorized(r) {
+			http.Error(w, "admin token required", http.StatusUnauthorized)
+			return
+		}
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, s.upstream.JoinPath("db").String(), nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+		w.WriteHeader(resp.StatusCode)
+		_, _ = io.Copy(w, resp.Body)
+	})
+	return mux
+}
+
+// adminAuthorized reports if the request has the admin token, requests are rejected if no token is configured.
+func (s *Server) adminAuthorized(r *http.Request) bool {
+	if s.config.AdminTokenFile == "" {
+		return false
+	}
+	expected, err := s.files.read(s.config.AdminTokenFile)
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return err == nil && expected != "" && subtle.ConstantTimeCompare([]byte(expected), []byte(token)) == 1
+}
+
+func (s *Server) Stats() Stats {
+	return Stats{
+		StartTime:               s.startTime,
+		LastRequestTime:         time.Unix(0, s.lastRequest.Load()),
+		Requests:                s.requests.Load(),
+		UnauthenticatedRequests: s.unauthenticated.Load(),
+	}
+}
+
+// PrincipalFromRequest returns the principal of an authenticated request.
+func PrincipalFromRequest(r *http.Request) *Principal {
+	principal, _ := r.Context().Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// statusRecorder keeps the status code, size and the beginning of the body (up to bodyLimit bytes, plus one to detect a longer body) of a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status    int
+	size      int
+	bodyLimit int
+	body      bytes.Buffer
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if remaining := s.bodyLimit + 1 - s.body.Len(); s.bodyLimit > 0 && remaining > 0 {
+		if remaining > len(b) {
+			remaining = len(b)
+		}
+		s.body.Write(b[:remaining])
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.size += n
+	return n, err
+}
+
+// Flush supports streamed responses of the reverse proxy.
+func (s *statusRecorder) Flush() {
+	if flusher, ok := s.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,74 @@
+package sidecar
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServer_Handler(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/db" {
+			_, _ = w.Write([]byte(`{"people":[]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer upstream.Close()
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(tokenFile, []byte("admin-secret\n"), 0600))
+	server, err := NewServer(&Config{Upstream: upstream.URL, Auth: &AuthConfig{AnonymousRead: true}, AdminTokenFile: tokenFile})
+	assert.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/people", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `[]`, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest("POST", "/people", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	stats := server.Stats()
+	assert.Equal(t, int64(2), stats.Requests)
+	assert.Equal(t, int64(1), stats.UnauthenticatedRequests)
+
+	rec = httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	server.AdminHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/db", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/db", nil)
+	req.Header.Set("Authorization", "Bearer other")
+	server.AdminHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/db", nil)
+	req.Header.Set("Authorization", "Bearer admin-secret")
+	server.AdminHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"people":[]}`, rec.Body.String())
+}
+
+func TestServer_AdminHandler_withoutToken(t *testing.T) {
+	server, err := NewServer(&Config{Upstream: "http://127.0.0.1:3100"})
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/db", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	server.AdminHandler().ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code, "the database is not served if no token is configured")
+}
+
+func TestNewServer_missingCertificate(t *testing.T) {
+	_, err := NewServer(&Config{Upstream: "http://127.0.0.1:3100", TLS: &TLSConfig{CertFile: "/missing/tls.crt", KeyFile: "/missing/tls.key"}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package sidecar
+
+import (
+	"crypto/tls"
+	"github.com/pkg/errors"
+	"sync"
+	"time"
+)
+
+// certificateLoader loads the certificate from files, it keeps the last valid one if the files cannot be loaded.
+type certificateLoader struct {
+	config      *TLSConfig
+	ttl         time.Duration
+	mutex       sync.Mutex
+	certificate *tls.Certificate
+	loaded      time.Time
+}
+
+func newCertificateLoader(config *TLSConfig, ttl time.Duration) *certificateLoader {
+	return &certificateLoader{config: config, ttl: ttl}
+}
+
+func (l *certificateLoader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.certificate != nil && time.Since(l.loaded) < l.ttl {
+		return l.certificate, nil
+	}
+	certificate, err := tls.LoadX509KeyPair(l.config.CertFile, l.config.KeyFile)
+	if err != nil {
+		if l.certificate != nil {
+			return l.certificate, nil
+		}
+		return nil, errors.Wrapf(err, "cannot load certificate %s", l.config.CertFile)
+	}
+	l.certificate = &certificate
+	l.loaded = time.Now()
+	return l.certificate, nil
+}
@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"context"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"os"
+)
+
+const serviceName = "json-server-operator"
+
+// Options of the OTLP exporter, tracing is disabled without an endpoint.
+type Options struct {
+	// Endpoint is host:port of an OTLP/HTTP collector, OTEL_EXPORTER_OTLP_ENDPOINT or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT by default
+	Endpoint string
+	// Insecure sends spans over plain HTTP
+	Insecure bool
+	// SampleRatio of traces, 1 records all of them
+	SampleRatio float64
+}
+
+// Enabled reports if an endpoint is set by options or the environment.
+func (o Options) Enabled() bool {
+	return o.Endpoint != "" || os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup registers a global TracerProvider exporting spans with OTLP/HTTP, the returned function flushes and stops it.
+// Other OTEL_EXPORTER_OTLP_* and OTEL_RESOURCE_ATTRIBUTES environment variables are applied by the SDK.
+func Setup(ctx context.Context, options Options) (func(context.Context) error, error) {
+	exporterOptions := make([]otlptracehttp.Option, 0)
+	if options.Endpoint != "" {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithEndpoint(options.Endpoint))
+	}
+	if options.Insecure {
+		exporterOptions = append(exporterOptions, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, exporterOptions...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create OTLP exporter")
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithTelemetrySDK(),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot create tracing resource")
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(options.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// End ends span, recording err if it is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -1,16 +1,217 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"io"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/yaml"
 	"os"
+	sigsyaml "sigs.k8s.io/yaml"
+	"strings"
 )
 
 const file = "/operator-resources.yaml"
 
+// clusterScopedResources are resources of the manager role that cannot be granted by a Role
+var clusterScopedResources = map[string]bool{
+	"jsonserverclasses": true,
+	"namespaces":        true,
+}
+
 func main() {
+	var namespaces string
+	flag.StringVar(&namespaces, "namespaces", "",
+		"Comma separated list of namespaces watched by the operator. Resources are printed with Roles in these namespaces instead of cluster-wide permissions.")
+	flag.Parse()
 	buf, err := os.ReadFile(file)
 	if err != nil {
 		panic(err)
 	}
+	if namespaces != "" {
+		if buf, err = namespaceScoped(buf, strings.Split(namespaces, ",")); err != nil {
+			panic(err)
+		}
+	}
 	fmt.Println(string(buf))
 }
+
+// activatorRules are granted in the namespace of the operator when it is not watched,
+// the manager reads endpoints of the activator Service there
+var activatorRules = []interface{}{
+	map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"endpoints"}, "verbs": []interface{}{"get"}},
+}
+
+// namespaceScoped converts operator resources to watch only given namespaces:
+// the manager ClusterRole is replaced by Roles in the namespaces (only cluster-scoped resources are kept in the ClusterRole),
+// the manager gets a Role for the activator endpoints in its own namespace and it is started with --watch-namespaces.
+func namespaceScoped(resources []byte, namespaces []string) ([]byte, error) {
+	watched := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			watched = append(watched, ns)
+		}
+	}
+	objects, err := decode(resources)
+	if err != nil {
+		return nil, err
+	}
+	managerRole := ""
+	operatorNamespace := ""
+	for _, o := range objects {
+		if o.GetKind() == "ClusterRole" && strings.HasSuffix(o.GetName(), "manager-role") {
+			managerRole = o.GetName()
+		}
+		if o.GetKind() == "Deployment" {
+			operatorNamespace = o.GetNamespace()
+		}
+	}
+	if managerRole == "" {
+		return nil, fmt.Errorf("manager ClusterRole not found")
+	}
+	activatorNamespace := operatorNamespace
+	for _, ns := range watched {
+		if ns == operatorNamespace {
+			activatorNamespace = ""
+		}
+	}
+	out := make([]*unstructured.Unstructured, 0, len(objects))
+	for _, o := range objects {
+		switch {
+		case o.GetKind() == "ClusterRole" && o.GetName() == managerRole:
+			clusterRules, namespacedRules := splitRules(o.Object["rules"])
+			o.Object["rules"] = clusterRules
+			out = append(out, o)
+			for _, ns := range watched {
+				role := o.DeepCopy()
+				role.SetKind("Role")
+				role.SetNamespace(ns)
+				role.Object["rules"] = namespacedRules
+				out = append(out, role)
+			}
+			if activatorNamespace != "" {
+				role := o.DeepCopy()
+				role.SetKind("Role")
+				role.SetName(managerRole + "-activator")
+				role.SetNamespace(activatorNamespace)
+				role.Object["rules"] = activatorRules
+				out = append(out, role)
+			}
+		case o.GetKind() == "ClusterRoleBinding" && nestedString(o, "roleRef", "name") == managerRole:
+			out = append(out, o)
+			for _, ns := range watched {
+				binding := o.DeepCopy()
+				binding.SetKind("RoleBinding")
+				binding.SetNamespace(ns)
+				_ = unstructured.SetNestedField(binding.Object, "Role", "roleRef", "kind")
+				out = append(out, binding)
+			}
+			if activatorNamespace != "" {
+				binding := o.DeepCopy()
+				binding.SetKind("RoleBinding")
+				binding.SetName(o.GetName() + "-activator")
+				binding.SetNamespace(activatorNamespace)
+				_ = unstructured.SetNestedField(binding.Object, "Role", "roleRef", "kind")
+				_ = unstructured.SetNestedField(binding.Object, managerRole+"-activator", "roleRef", "name")
+				out = append(out, binding)
+			}
+		case o.GetKind() == "Deployment":
+			if err := addManagerArg(o, "--watch-namespaces="+strings.Join(watched, ",")); err != nil {
+				return nil, err
+			}
+			out = append(out, o)
+		default:
+			out = append(out, o)
+		}
+	}
+	return encode(out)
+}
+
+// splitRules splits policy rules into rules for cluster-scoped and namespaced resources.
+func splitRules(rules interface{}) ([]interface{}, []interface{}) {
+	clusterRules := make([]interface{}, 0)
+	namespacedRules := make([]interface{}, 0)
+	items, _ := rules.([]interface{})
+	for _, item := range items {
+		rule, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		resources, _ := rule["resources"].([]interface{})
+		clusterResources := make([]interface{}, 0)
+		namespacedResources := make([]interface{}, 0)
+		for _, resource := range resources {
+			if name, _ := resource.(string); clusterScopedResources[name] {
+				clusterResources = append(clusterResources, resource)
+			} else {
+				namespacedResources = append(namespacedResources, resource)
+			}
+		}
+		if len(clusterResources) > 0 {
+			clusterRule := deepCopyMap(rule)
+			clusterRule["resources"] = clusterResources
+			clusterRules = append(clusterRules, clusterRule)
+		}
+		if len(namespacedResources) > 0 {
+			namespacedRule := deepCopyMap(rule)
+			namespacedRule["resources"] = namespacedResources
+			namespacedRules = append(namespacedRules, namespacedRule)
+		}
+	}
+	return clusterRules, namespacedRules
+}
+
+func addManagerArg(deployment *unstructured.Unstructured, arg string) error {
+	containers, _, err := unstructured.NestedSlice(deployment.Object, "spec", "template", "spec", "containers")
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok || container["name"] != "manager" {
+			continue
+		}
+		args, _ := container["args"].([]interface{})
+		container["args"] = append(args, arg)
+	}
+	return unstructured.SetNestedSlice(deployment.Object, containers, "spec", "template", "spec", "containers")
+}
+
+func deepCopyMap(m map[string]interface{}) map[string]interface{} {
+	return (&unstructured.Unstructured{Object: m}).DeepCopy().Object
+}
+
+func nestedString(o *unstructured.Unstructured, fields ...string) string {
+	value, _, _ := unstructured.NestedString(o.Object, fields...)
+	return value
+}
+
+func decode(resources []byte) ([]*unstructured.Unstructured, error) {
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(resources), 4096)
+	objects := make([]*unstructured.Unstructured, 0)
+	for {
+		o := &unstructured.Unstructured{}
+		if err := decoder.Decode(&o.Object); err != nil {
+			if err == io.EOF {
+				return objects, nil
+			}
+			return nil, err
+		}
+		if len(o.Object) > 0 {
+			objects = append(objects, o)
+		}
+	}
+}
+
+func encode(objects []*unstructured.Unstructured) ([]byte, error) {
+	docs := make([]string, 0, len(objects))
+	for _, o := range objects {
+		buf, err := sigsyaml.Marshal(o.Object)
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, string(buf))
+	}
+	return []byte(strings.Join(docs, "---\n")), nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const operatorResources = `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: json-server-operator-manager-role
+rules:
+- apiGroups: [""]
+  resources: [namespaces, pods]
+  verbs: [get]
+- apiGroups: [example.com]
+  resources: [jsonservers]
+  verbs: [get, list, watch]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: json-server-operator-manager-rolebinding
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: json-server-operator-manager-role
+subjects:
+- kind: ServiceAccount
+  name: json-server-operator-controller-manager
+  namespace: json-server-operator-system
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: json-server-operator-controller-manager
+  namespace: json-server-operator-system
+spec:
+  template:
+    spec:
+      containers:
+      - name: manager
+        args: [--leader-elect]
+`
+
+func Test_namespaceScoped(t *testing.T) {
+	buf, err := namespaceScoped([]byte(operatorResources), []string{"team-a", " team-b"})
+	assert.NoError(t, err)
+	objects, err := decode(buf)
+	assert.NoError(t, err)
+	kinds := make([]string, 0)
+	for _, o := range objects {
+		kinds = append(kinds, o.GetKind()+"/"+o.GetNamespace())
+	}
+	assert.Equal(t, []string{"ClusterRole/", "Role/team-a", "Role/team-b", "Role/json-server-operator-system", "ClusterRoleBinding/", "RoleBinding/team-a", "RoleBinding/team-b", "RoleBinding/json-server-operator-system", "Deployment/json-server-operator-system"}, kinds)
+	assert.Equal(t, []interface{}{map[string]interface{}{"apiGroups": []interface{}{""}, "resources": []interface{}{"namespaces"}, "verbs": []interface{}{"get"}}}, objects[0].Object["rules"])
+	assert.Len(t, objects[1].Object["rules"], 2)
+	assert.Equal(t, activatorRules, objects[3].Object["rules"])
+	assert.Equal(t, "Role", nestedString(objects[5], "roleRef", "kind"))
+	assert.Equal(t, objects[3].GetName(), nestedString(objects[7], "roleRef", "name"))
+	containers := objects[8].Object["spec"].(map[string]interface{})["template"].(map[string]interface{})["spec"].(map[string]interface{})["containers"].([]interface{})
+	assert.Equal(t, []interface{}{"--leader-elect", "--watch-namespaces=team-a,team-b"}, containers[0].(map[string]interface{})["args"])
+}
+
+func Test_namespaceScoped_operatorNamespaceWatched(t *testing.T) {
+	buf, err := namespaceScoped([]byte(operatorResources), []string{"json-server-operator-system"})
+	assert.NoError(t, err)
+	objects, err := decode(buf)
+	assert.NoError(t, err)
+	kinds := make([]string, 0)
+	for _, o := range objects {
+		kinds = append(kinds, o.GetKind()+"/"+o.GetNamespace())
+	}
+	assert.Equal(t, []string{"ClusterRole/", "Role/json-server-operator-system", "ClusterRoleBinding/", "RoleBinding/json-server-operator-system", "Deployment/json-server-operator-system"}, kinds)
+}